@@ -0,0 +1,173 @@
+// Package bind bind the request into a struct with gin and turn the validation errors
+// into a list of fields named after their json tags, with messages in the language of
+// the request:
+//
+//	var req CreateUserRequest
+//	if err := bind.JSON(c, &req); err != nil {
+//		response.Fail(c, err)
+//		return
+//	}
+//
+// The error is a response.ErrBadRequest carrying the []FieldError as data
+package bind
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/chenxuan520/goweb-platform/response"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	zh_translations "github.com/go-playground/validator/v10/translations/zh"
+)
+
+// DefaultLanguage translate the errors of a request asking for no supported language
+const DefaultLanguage = "en"
+
+// FieldError is an invalid field of the request
+type FieldError struct {
+	// Field is the dotted path of json names, such as user.emails[0]
+	Field   string `json:"field"`
+	Tag     string `json:"tag"`
+	Message string `json:"message"`
+}
+
+var (
+	setupErr error
+	uni      *ut.UniversalTranslator
+)
+
+// the validator of gin is set up on import: its register functions must not run while
+// requests are validated, and it caches the field names of a struct on its first validation
+func init() {
+	setupErr = setup()
+}
+
+// setup configure the validator of gin: json names, translations and the validators of this package
+func setup() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("bind:gin validator is not a go-playground validator")
+	}
+	v.RegisterTagNameFunc(fieldName)
+	uni = ut.New(en.New(), en.New(), zh.New())
+	enTrans, _ := uni.GetTranslator("en")
+	zhTrans, _ := uni.GetTranslator("zh")
+	if err := en_translations.RegisterDefaultTranslations(v, enTrans); err != nil {
+		return err
+	}
+	if err := zh_translations.RegisterDefaultTranslations(v, zhTrans); err != nil {
+		return err
+	}
+	for _, c := range builtinValidations {
+		if err := register(v, c.tag, c.fn, c.messages); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldName name a field after its json tag, then its form tag, then itself
+func fieldName(field reflect.StructField) string {
+	for _, key := range []string{"json", "form"} {
+		name := strings.SplitN(field.Tag.Get(key), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// JSON bind the json body into obj and validate it
+func JSON(c *gin.Context, obj interface{}) error {
+	return With(c, obj, binding.JSON)
+}
+
+// Query bind the query string into obj and validate it
+func Query(c *gin.Context, obj interface{}) error {
+	return With(c, obj, binding.Query)
+}
+
+// URI bind the path parameters into obj and validate it
+func URI(c *gin.Context, obj interface{}) error {
+	if setupErr != nil {
+		return setupErr
+	}
+	m := make(map[string][]string)
+	for _, p := range c.Params {
+		m[p.Key] = []string{p.Value}
+	}
+	return Translate(c, binding.Uri.BindUri(m, obj))
+}
+
+// Bind bind the body into obj with the binding of its content type, the query for a GET
+func Bind(c *gin.Context, obj interface{}) error {
+	return With(c, obj, binding.Default(c.Request.Method, c.ContentType()))
+}
+
+// With bind into obj with b and validate it
+func With(c *gin.Context, obj interface{}, b binding.Binding) error {
+	if setupErr != nil {
+		return setupErr
+	}
+	return Translate(c, c.ShouldBindWith(obj, b))
+}
+
+// Translate turn a binding error into a response.ErrBadRequest: the validation errors
+// become its []FieldError data in the language of the request, any other error, such
+// as a malformed body, is its cause. A nil err stays nil
+func Translate(c *gin.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return response.ErrBadRequest.Wrap(err)
+	}
+	return response.ErrBadRequest.Wrap(err).WithData(Fields(errs, c.GetHeader("Accept-Language")))
+}
+
+// Fields translate errs into the first language of acceptLanguage supported, en or zh
+func Fields(errs validator.ValidationErrors, acceptLanguage string) []FieldError {
+	if setupErr != nil {
+		return nil
+	}
+	trans, _ := uni.GetTranslator(language(acceptLanguage))
+	fields := make([]FieldError, 0, len(errs))
+	for _, fe := range errs {
+		fields = append(fields, FieldError{Field: fieldPath(fe), Tag: fe.Tag(), Message: fe.Translate(trans)})
+	}
+	return fields
+}
+
+// fieldPath drop the struct name heading the namespace
+func fieldPath(fe validator.FieldError) string {
+	ns := fe.Namespace()
+	if i := strings.IndexByte(ns, '.'); i >= 0 {
+		return ns[i+1:]
+	}
+	return ns
+}
+
+// language pick the first supported language of an Accept-Language header
+func language(acceptLanguage string) string {
+	for _, lang := range strings.Split(acceptLanguage, ",") {
+		lang = strings.ToLower(strings.TrimSpace(strings.SplitN(lang, ";", 2)[0]))
+		if i := strings.IndexByte(lang, '-'); i > 0 {
+			lang = lang[:i]
+		}
+		if _, found := uni.FindTranslator(lang); found {
+			return lang
+		}
+	}
+	return DefaultLanguage
+}
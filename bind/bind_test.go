@@ -0,0 +1,100 @@
+package bind
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chenxuan520/goweb-platform/response"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+)
+
+type address struct {
+	City string `json:"city" binding:"required"`
+}
+
+type createUser struct {
+	Name     string    `json:"name" binding:"required,min=2"`
+	Mobile   string    `json:"mobile" binding:"omitempty,mobile"`
+	IDCard   string    `json:"id_card" binding:"omitempty,idcard"`
+	Nickname string    `json:"nickname,omitempty" binding:"omitempty,even"`
+	Address  address   `json:"address"`
+	Tags     []address `json:"tags" binding:"dive"`
+}
+
+func bindJSON(body, acceptLanguage string) error {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Request.Header.Set("Accept-Language", acceptLanguage)
+	var req createUser
+	return JSON(c, &req)
+}
+
+func TestJSON(t *testing.T) {
+	assert.Nil(t, RegisterValidation("even", func(fl validator.FieldLevel) bool {
+		return len(fl.Field().String())%2 == 0
+	}, Messages{"en": "{0} must have an even length", "zh": "{0}长度必须为偶数"}))
+
+	assert.Nil(t, bindJSON(`{"name": "alice", "mobile": "13812345678", "id_card": "11010519491231002X", "address": {"city": "Wuhan"}}`, ""))
+
+	err := bindJSON(`{"name": "a", "mobile": "12345", "id_card": "110105194912310021", "nickname": "odd", "tags": [{}]}`, "en-US,en;q=0.9")
+	assert.True(t, errors.Is(err, response.ErrBadRequest))
+	var e *response.Error
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, []FieldError{
+		{Field: "name", Tag: "min", Message: "name must be at least 2 characters in length"},
+		{Field: "mobile", Tag: "mobile", Message: "mobile must be a valid mobile number"},
+		{Field: "id_card", Tag: "idcard", Message: "id_card must be a valid ID card number"},
+		{Field: "nickname", Tag: "even", Message: "nickname must have an even length"},
+		{Field: "address.city", Tag: "required", Message: "city is a required field"},
+		{Field: "tags[0].city", Tag: "required", Message: "city is a required field"},
+	}, e.Data)
+
+	err = bindJSON(`{"address": {"city": "Wuhan"}, "mobile": "1381234"}`, "zh-CN,zh;q=0.9")
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, []FieldError{
+		{Field: "name", Tag: "required", Message: "name为必填字段"},
+		{Field: "mobile", Tag: "mobile", Message: "mobile必须是有效的手机号码"},
+	}, e.Data)
+
+	// a malformed body is a bad request without fields
+	err = bindJSON(`{"name":`, "")
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, 40000, e.Code)
+	assert.Nil(t, e.Data)
+}
+
+func TestRegisterValidation(t *testing.T) {
+	// a validator of its own, the one of gin stays untouched by the test
+	err := register(validator.New(), "upper", func(fl validator.FieldLevel) bool { return true }, Messages{"fr": "{0}"})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unsupported language fr")
+}
+
+func TestBindAfterGin(t *testing.T) {
+	type login struct {
+		UserName string `json:"user_name" binding:"required"`
+	}
+	gin.SetMode(gin.TestMode)
+	newContext := func() *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+		c.Request.Header.Set("Content-Type", "application/json")
+		return c
+	}
+
+	// gin validates the type first and caches its field names
+	var req login
+	assert.NotNil(t, newContext().ShouldBindJSON(&req))
+
+	err := JSON(newContext(), &req)
+	var e *response.Error
+	assert.True(t, errors.As(err, &e))
+	assert.Equal(t, []FieldError{{Field: "user_name", Tag: "required", Message: "user_name is a required field"}}, e.Data)
+}
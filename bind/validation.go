@@ -0,0 +1,90 @@
+package bind
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+)
+
+// Messages map a language, en or zh, to the message of a validation tag.
+// {0} is the field name and {1} the tag parameter
+type Messages map[string]string
+
+type validation struct {
+	tag      string
+	fn       validator.Func
+	messages Messages
+}
+
+// builtinValidations are the tags this package adds
+var builtinValidations = []validation{
+	{tag: "mobile", fn: isMobile, messages: Messages{"en": "{0} must be a valid mobile number", "zh": "{0}必须是有效的手机号码"}},
+	{tag: "idcard", fn: isIDCard, messages: Messages{"en": "{0} must be a valid ID card number", "zh": "{0}必须是有效的身份证号码"}},
+}
+
+// mobileRegexp match a mainland China mobile number, optionally prefixed with +86
+var mobileRegexp = regexp.MustCompile(`^(\+?86)?1[3-9]\d{9}$`)
+
+func isMobile(fl validator.FieldLevel) bool {
+	return mobileRegexp.MatchString(fl.Field().String())
+}
+
+var (
+	idCardRegexp  = regexp.MustCompile(`^\d{17}[\dX]$`)
+	idCardWeights = []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	idCardChecks  = "10X98765432"
+)
+
+// isIDCard check an 18 digits resident ID card number and its check digit
+func isIDCard(fl validator.FieldLevel) bool {
+	id := strings.ToUpper(fl.Field().String())
+	if !idCardRegexp.MatchString(id) {
+		return false
+	}
+	sum := 0
+	for i, w := range idCardWeights {
+		sum += int(id[i]-'0') * w
+	}
+	return id[17] == idCardChecks[sum%11]
+}
+
+// RegisterValidation add a validation tag to the validator of gin with its messages,
+// a language without message falls back to the error of the validator. Call it before
+// serving, from an init or main, never while requests are validated
+func RegisterValidation(tag string, fn validator.Func, messages Messages) error {
+	if setupErr != nil {
+		return setupErr
+	}
+	v, _ := binding.Validator.Engine().(*validator.Validate)
+	return register(v, tag, fn, messages)
+}
+
+func register(v *validator.Validate, tag string, fn validator.Func, messages Messages) error {
+	if err := v.RegisterValidation(tag, fn); err != nil {
+		return fmt.Errorf("bind:register validation %s failed:%w", tag, err)
+	}
+	for lang, msg := range messages {
+		trans, found := uni.FindTranslator(lang)
+		if !found {
+			return fmt.Errorf("bind:unsupported language %s of validation %s", lang, tag)
+		}
+		msg := msg
+		err := v.RegisterTranslation(tag, trans, func(trans ut.Translator) error {
+			return trans.Add(tag, msg, true)
+		}, func(trans ut.Translator, fe validator.FieldError) string {
+			t, err := trans.T(fe.Tag(), fe.Field(), fe.Param())
+			if err != nil {
+				return fe.Error()
+			}
+			return t
+		})
+		if err != nil {
+			return fmt.Errorf("bind:register translation %s of validation %s failed:%w", lang, tag, err)
+		}
+	}
+	return nil
+}
@@ -2,10 +2,7 @@ package platform
 
 import (
 	"fmt"
-	"github.com/chenxuan520/goweb-platform/utils"
-	"github.com/fsnotify/fsnotify"
-	"github.com/spf13/viper"
-	"path"
+	"time"
 )
 
 const (
@@ -14,6 +11,8 @@ const (
 	extensionInI  = ".ini"
 
 	nameSpace = "conf"
+
+	defaultLogDirector = "log"
 )
 
 var (
@@ -27,40 +26,83 @@ var (
 
 type (
 	Mysql struct {
-		Path     string `mapstructure:"path" json:"path" yaml:"path" ini:"path"`                 // 服务器地址
-		Port     string `mapstructure:"port" json:"port" yaml:"port" ini:"port"`                 // 端口
-		Config   string `mapstructure:"config" json:"config" yaml:"config" ini:"config"`         // 高级配置
-		Dbname   string `mapstructure:"db-name" json:"dbname" yaml:"db-name" ini:"db-name"`      // 数据库名
-		Username string `mapstructure:"username" json:"username" yaml:"username" ini:"username"` // 数据库用户名
-		Password string `mapstructure:"password" json:"password" yaml:"password" ini:"password"` // 数据库密码
+		Path     string `mapstructure:"path" json:"path" yaml:"path" ini:"path" validate:"required_with=Dbname"` // 服务器地址
+		Port     string `mapstructure:"port" json:"port" yaml:"port" ini:"port" validate:"omitempty,numeric"`    // 端口
+		Config   string `mapstructure:"config" json:"config" yaml:"config" ini:"config"`                         // 高级配置
+		Dbname   string `mapstructure:"db-name" json:"dbname" yaml:"db-name" ini:"db-name"`                      // 数据库名
+		Username string `mapstructure:"username" json:"username" yaml:"username" ini:"username"`                 // 数据库用户名
+		Password string `mapstructure:"password" json:"password" yaml:"password" ini:"password" secret:"true"`   // 数据库密码
 	}
 	Redis struct {
-		DB       int    `mapstructure:"db" json:"db" yaml:"db" ini:"db"`                         // redis的哪个数据库
-		Addr     string `mapstructure:"addr" json:"addr" yaml:"addr" ini:"addr"`                 // 服务器地址:端口
-		Password string `mapstructure:"password" json:"password" yaml:"password" ini:"password"` // 密码
+		DB       int    `mapstructure:"db" json:"db" yaml:"db" ini:"db"`                                            // redis的哪个数据库
+		Addr     string `mapstructure:"addr" json:"addr" yaml:"addr" ini:"addr" validate:"omitempty,hostname_port"` // 服务器地址:端口
+		Password string `mapstructure:"password" json:"password" yaml:"password" ini:"password" secret:"true"`      // 密码
 	}
 	Mongo struct {
-		Host     string `mapstructure:"host" json:"host" yaml:"host" ini:"host"`
-		Port     string `mapstructure:"port" json:"port" yaml:"port" ini:"port"`
+		Host     string `mapstructure:"host" json:"host" yaml:"host" ini:"host" validate:"required_with=DBname"`
+		Port     string `mapstructure:"port" json:"port" yaml:"port" ini:"port" validate:"omitempty,numeric"`
 		User     string `mapstructure:"user" json:"user" yaml:"user" ini:"user"`
-		Password string `mapstructure:"password" json:"password" yaml:"password" ini:"password"`
+		Password string `mapstructure:"password" json:"password" yaml:"password" ini:"password" secret:"true"`
 		DBname   string `mapstructure:"db" json:"db" yaml:"db" ini:"db"`
 	}
 	System struct {
 		Env        string `mapstructure:"env" json:"env" yaml:"env" ini:"env"`
-		Addr       int    `mapstructure:"addr" json:"addr" yaml:"addr" ini:"addr"`
+		Addr       int    `mapstructure:"addr" json:"addr" yaml:"addr" ini:"addr" validate:"omitempty,min=1,max=65535"`
 		UploadType string `mapstructure:"upload-type" json:"upload-type" yaml:"upload-type" ini:"upload-type"` // Oss类型
 		Version    string `mapstructure:"version" json:"version" yaml:"version" ini:"version"`
+		AdminToken string `mapstructure:"admin-token" json:"admin-token" yaml:"admin-token" ini:"admin-token" secret:"true"` // 管理接口的 Bearer token, 为空则不开启
+
+		ReadTimeout       time.Duration `mapstructure:"read-timeout" json:"readTimeout" yaml:"read-timeout" ini:"read-timeout"`                                 // 默认 20s, 负数不限制
+		ReadHeaderTimeout time.Duration `mapstructure:"read-header-timeout" json:"readHeaderTimeout" yaml:"read-header-timeout" ini:"read-header-timeout"`      // 默认同 read-timeout
+		WriteTimeout      time.Duration `mapstructure:"write-timeout" json:"writeTimeout" yaml:"write-timeout" ini:"write-timeout"`                             // 默认 20s, 负数不限制, 流式接口用 server.WithWriteTimeout 单独放宽
+		IdleTimeout       time.Duration `mapstructure:"idle-timeout" json:"idleTimeout" yaml:"idle-timeout" ini:"idle-timeout"`                                 // keep-alive 空闲连接超时, 默认同 read-timeout
+		MaxHeaderBytes    int           `mapstructure:"max-header-bytes" json:"maxHeaderBytes" yaml:"max-header-bytes" ini:"max-header-bytes" validate:"min=0"` // 默认 1MB
+		MaxBodySize       int64         `mapstructure:"max-body-size" json:"maxBodySize" yaml:"max-body-size" ini:"max-body-size" validate:"min=0"`             // 请求体最大字节数, 0 不限制
+		DisableKeepAlive  bool          `mapstructure:"disable-keep-alive" json:"disableKeepAlive" yaml:"disable-keep-alive" ini:"disable-keep-alive"`          // 关闭 keep-alive
+		ShutdownWait      time.Duration `mapstructure:"shutdown-wait" json:"shutdownWait" yaml:"shutdown-wait" ini:"shutdown-wait"`                             // 停机时先让就绪检查失败的等待, 默认 1s, 负数不等待
+		ShutdownTimeout   time.Duration `mapstructure:"shutdown-timeout" json:"shutdownTimeout" yaml:"shutdown-timeout" ini:"shutdown-timeout"`                 // 停机总超时, 默认 15s
 	}
 	Log struct {
-		Level         string `mapstructure:"level" json:"level" yaml:"level" ini:"level"`                                    // 级别
-		Format        string `mapstructure:"format" json:"format" yaml:"format" ini:"level"`                                 // 输出
-		Prefix        string `mapstructure:"prefix" json:"prefix" yaml:"prefix" ini:"level"`                                 // 日志前缀
-		Director      string `mapstructure:"director" json:"director"  yaml:"director" ini:"level"`                          // 日志文件夹
-		ShowLine      bool   `mapstructure:"show-line" json:"showLine" yaml:"showLine" ini:"showLine"`                       // 显示行
-		EncodeLevel   string `mapstructure:"encode-level" json:"encodeLevel" yaml:"encode-level" ini:"encode-level"`         // 编码级
-		StacktraceKey string `mapstructure:"stacktrace-key" json:"stacktraceKey" yaml:"stacktrace-key" ini:"stacktrace-key"` // 栈名
-		LogInConsole  bool   `mapstructure:"log-in-console" json:"logInConsole" yaml:"log-in-console" ini:"log-in-console"`  // 输出控制台
+		Level         string `mapstructure:"level" json:"level" yaml:"level" ini:"level" validate:"omitempty,oneof=debug info warn error"` // 级别
+		Format        string `mapstructure:"format" json:"format" yaml:"format" ini:"level"`                                               // 输出, json 以外都按 console
+		Prefix        string `mapstructure:"prefix" json:"prefix" yaml:"prefix" ini:"level"`                                               // 日志前缀
+		Director      string `mapstructure:"director" json:"director"  yaml:"director" ini:"level"`                                        // 日志文件夹, 默认 log
+		ShowLine      bool   `mapstructure:"show-line" json:"showLine" yaml:"showLine" ini:"showLine"`                                     // 显示行
+		EncodeLevel   string `mapstructure:"encode-level" json:"encodeLevel" yaml:"encode-level" ini:"encode-level"`                       // 编码级
+		StacktraceKey string `mapstructure:"stacktrace-key" json:"stacktraceKey" yaml:"stacktrace-key" ini:"stacktrace-key"`               // 栈名
+		LogInConsole  bool   `mapstructure:"log-in-console" json:"logInConsole" yaml:"log-in-console" ini:"log-in-console"`                // 输出控制台
+	}
+	AccessLog struct {
+		Enable      bool     `mapstructure:"enable" json:"enable" yaml:"enable" ini:"enable"`                                            // 开启访问日志
+		Format      string   `mapstructure:"format" json:"format" yaml:"format" ini:"format" validate:"omitempty,oneof=json combined"`   // json 或 combined
+		Output      string   `mapstructure:"output" json:"output" yaml:"output" ini:"output" validate:"omitempty,oneof=logger file"`     // logger 或 file
+		Filename    string   `mapstructure:"filename" json:"filename" yaml:"filename" ini:"filename"`                                    // file 输出的文件, 默认 <log.director>/access.log
+		SkipPaths   []string `mapstructure:"skip-paths" json:"skipPaths" yaml:"skip-paths" ini:"skip-paths"`                             // 不记录的路径, 支持 /prefix/* 前缀
+		SampleRate  float64  `mapstructure:"sample-rate" json:"sampleRate" yaml:"sample-rate" ini:"sample-rate" validate:"min=0,max=1"`  // 2xx 响应的采样率, 0 视为全部记录
+		CaptureBody bool     `mapstructure:"capture-body" json:"captureBody" yaml:"capture-body" ini:"capture-body"`                     // 记录请求与响应体
+		MaxBodySize int      `mapstructure:"max-body-size" json:"maxBodySize" yaml:"max-body-size" ini:"max-body-size" validate:"min=0"` // 记录的 body 最大字节数, 默认 4096
+	}
+	Listener struct {
+		Network      string `mapstructure:"network" json:"network" yaml:"network" ini:"network" validate:"omitempty,oneof=tcp unix"`                  // tcp 或 unix, 默认 tcp
+		Addr         string `mapstructure:"addr" json:"addr" yaml:"addr" ini:"addr" validate:"required"`                                              // host:port 或 unix socket 路径
+		CertFile     string `mapstructure:"cert-file" json:"certFile" yaml:"cert-file" ini:"cert-file" validate:"required_with=KeyFile ClientCAFile"` // 开启 https, 证书轮换后自动加载
+		KeyFile      string `mapstructure:"key-file" json:"keyFile" yaml:"key-file" ini:"key-file" validate:"required_with=CertFile"`                 // 私钥
+		ClientCAFile string `mapstructure:"client-ca-file" json:"clientCAFile" yaml:"client-ca-file" ini:"client-ca-file"`                            // 双向 TLS, 校验客户端证书的 CA
+		H2C          bool   `mapstructure:"h2c" json:"h2c" yaml:"h2c" ini:"h2c" validate:"excluded_with=CertFile"`                                    // 明文 HTTP/2
+		SocketMode   string `mapstructure:"socket-mode" json:"socketMode" yaml:"socket-mode" ini:"socket-mode" validate:"omitempty,numeric"`          // unix socket 权限, 如 0660
+	}
+	Cors struct {
+		AllowAll bool         `mapstructure:"allow-all" json:"allowAll" yaml:"allow-all" ini:"allow-all"`              // 反射任意 Origin 并允许凭证, 仅 testing 环境生效
+		Policies []CorsPolicy `mapstructure:"policies" json:"policies" yaml:"policies" ini:"policies" validate:"dive"` // 按路径前缀匹配, 最长前缀优先; 为空则不返回 CORS 头
+	}
+	CorsPolicy struct {
+		Prefix           string        `mapstructure:"prefix" json:"prefix" yaml:"prefix" ini:"prefix" validate:"omitempty,startswith=/"`                               // 路由前缀, 如 /open, 为空匹配全部路径
+		AllowOrigins     []string      `mapstructure:"allow-origins" json:"allowOrigins" yaml:"allow-origins" ini:"allow-origins" validate:"required,dive,cors_origin"` // 完整 Origin, * 全部, https://*.example.com 通配子域名, ~ 开头为正则, 需匹配整个 Origin
+		AllowMethods     []string      `mapstructure:"allow-methods" json:"allowMethods" yaml:"allow-methods" ini:"allow-methods"`                                      // 默认 GET, POST, PUT, PATCH, DELETE, HEAD
+		AllowHeaders     []string      `mapstructure:"allow-headers" json:"allowHeaders" yaml:"allow-headers" ini:"allow-headers"`                                      // 默认 Content-Type, Authorization 等常用头
+		ExposeHeaders    []string      `mapstructure:"expose-headers" json:"exposeHeaders" yaml:"expose-headers" ini:"expose-headers"`                                  // 默认 Content-Length, X-Request-ID
+		AllowCredentials bool          `mapstructure:"allow-credentials" json:"allowCredentials" yaml:"allow-credentials" ini:"allow-credentials"`                      // 允许携带 cookie, 不能与 * 同时使用
+		MaxAge           time.Duration `mapstructure:"max-age" json:"maxAge" yaml:"max-age" ini:"max-age" validate:"min=0"`                                             // 预检结果的缓存时间, 0 不缓存
 	}
 )
 
@@ -70,6 +112,11 @@ type Config struct {
 	Mysql  Mysql  `mapstructure:"mysql" json:"mysql" yaml:"mysql" ini:"mysql"`
 	Redis  Redis  `mapstructure:"redis" json:"redis" yaml:"redis" ini:"redis"`
 	Mongo  Mongo  `mapstructure:"mongo" json:"mongo" yaml:"mongo" ini:"mongo"`
+
+	AccessLog AccessLog `mapstructure:"access-log" json:"accessLog" yaml:"access-log" ini:"access-log"`
+	// Listeners serve the same routes besides system.addr, such as https or a unix socket
+	Listeners []Listener `mapstructure:"listeners" json:"listeners" yaml:"listeners" ini:"listeners" validate:"dive"`
+	Cors      Cors       `mapstructure:"cors" json:"cors" yaml:"cors" ini:"cors"`
 }
 
 func (m *Mysql) Dsn() string {
@@ -86,43 +133,57 @@ func (m *Mysql) EmptyDsn() string {
 	return fmt.Sprintf("%s:%s@tcp(%s:%s)/", m.Username, m.Password, m.Path, m.Port)
 }
 
-var _defaultConfig *Config
-
-func LoadConfig(env, configFileName string) (*Config, error) {
-	var c Config
-	var confPath string
-	dir := fmt.Sprintf("%s/%s", nameSpace, env)
-	for _, registerExt := range autoLoadLocalConfigs {
-		confPath = path.Join(dir, configFileName+registerExt)
-		if utils.Exists(confPath) {
-			break
-		}
-	}
-	fmt.Println("the path to the configuration file you are using is :", confPath)
-	v := viper.New()
-	v.SetConfigFile(confPath)
-	ext := utils.Ext(confPath)
-	v.SetConfigType(ext)
-	err := v.ReadInConfig()
+// Validate check the validate tags of every key, all invalid keys are reported in one *ValidationError
+func (c *Config) Validate() error {
+	return c.validate(nil)
+}
+
+// applyDefaults fill the keys the loaded configs may leave empty but the platform cannot run without
+func (c *Config) applyDefaults() {
+	if c.Log.Director == "" {
+		c.Log.Director = defaultLogDirector
+	}
+}
+
+func (c *Config) validate(origin func(key string) string) error {
+	if errs := structErrors("", c, origin); len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// LoadConfig load conf/<env>/<configFileName>.{json,yaml,ini} and watch it for changes.
+// conf/base/<configFileName> is loaded first and the env file is deep merged on top, then the
+// gitignored conf/<env>/<configFileName>.local file. Any file may list other files to merge
+// before itself under the include key, relative to its own directory.
+// WithSources replace the files by other sources, such as an HTTP endpoint or a KV store.
+// ENC(...) values are decrypted with the key of WithSecretKeyFile, SecretKeyEnv or SecretKeyFileEnv.
+// Values are resolved with the precedence source < env (WithEnvPrefix) < flag (WithOverrides)
+func LoadConfig(env, configFileName string, opts ...LoadOption) (*Config, error) {
+	l := newConfigLoader(env, configFileName, opts...)
+	layers, err := l.read()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("the path to the configuration file you are using is :", layers.names())
+	s, err := buildSnapshot(layers.v, layers.origin)
 	if err != nil {
-		panic(fmt.Errorf("Fatal error config file: %s \n", err))
-	}
-	v.WatchConfig()
-
-	v.OnConfigChange(func(e fsnotify.Event) {
-		fmt.Println("config file changed:", e.Name)
-		if err := v.Unmarshal(&c); err != nil {
-			fmt.Println(err)
-		}
-	})
-	if err := v.Unmarshal(&c); err != nil {
-		fmt.Println(err)
-	}
-	fmt.Printf("load config is :%#v\n", c)
-	_defaultConfig = &c
-	return &c, nil
+		return nil, err
+	}
+	fmt.Printf("load config is :%s\n", formatConfig(*s.config, layers.decrypted))
+	l.options.printSources(layers.v, layers.decrypted)
+	storeSnapshot(s)
+
+	l.watch()
+	return s.config, nil
 }
 
-func GetConfigModels() *Config {
-	return _defaultConfig
+// CheckConfig read and validate the config the same way as LoadConfig, without storing or watching it
+func CheckConfig(env, configFileName string, opts ...LoadOption) error {
+	layers, err := newConfigLoader(env, configFileName, opts...).read()
+	if err != nil {
+		return err
+	}
+	_, err = buildSnapshot(layers.v, layers.origin)
+	return err
 }
@@ -0,0 +1,153 @@
+package platform
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	reloadDebounce = 100 * time.Millisecond
+	// sourceLoadTimeout bound a single load of every source, a remote one may hang
+	sourceLoadTimeout = 10 * time.Second
+)
+
+// configLoader merge its sources in order and reload them all when any of them changes
+type configLoader struct {
+	sources []Source
+	options loadOptions
+
+	mu    sync.Mutex
+	timer *time.Timer
+	// reloadMu serialize the reloads, a slow remote source may outlive the debounce
+	reloadMu sync.Mutex
+}
+
+// newConfigLoader read the sources from opts, the files of env and name when none is given
+func newConfigLoader(env, name string, opts ...LoadOption) *configLoader {
+	l := &configLoader{}
+	for _, opt := range opts {
+		opt(&l.options)
+	}
+	l.sources = l.options.sources
+	if len(l.sources) == 0 {
+		l.sources = []Source{NewFileSource(env, name)}
+	}
+	return l
+}
+
+// configLayers is the result of reading every source once
+type configLayers struct {
+	v        *viper.Viper
+	sources  []Source
+	origins  map[string]Source
+	override *loadOptions
+	// decrypted list the keys whose value was an ENC(...) secret
+	decrypted map[string]bool
+}
+
+// origin name where the effective value of key comes from, for the validation report.
+// A key set nowhere belongs to the first source
+func (r *configLayers) origin(key string) string {
+	switch r.override.source(r.v, key) {
+	case sourceFlag:
+		return "--set " + key
+	case sourceEnv:
+		return "env " + r.override.envName(key)
+	}
+	src, ok := r.origins[key]
+	if !ok {
+		src = r.sources[0]
+	}
+	if o, ok := src.(originSource); ok {
+		return o.Origin(key)
+	}
+	return src.Name()
+}
+
+// names list the sources from the lowest to the highest precedence
+func (r *configLayers) names() string {
+	names := make([]string, 0, len(r.sources))
+	for _, src := range r.sources {
+		names = append(names, src.Name())
+	}
+	return strings.Join(names, " < ")
+}
+
+// read build a fresh viper from every source with the env and flag overrides bound
+func (l *configLoader) read() (*configLayers, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sourceLoadTimeout)
+	defer cancel()
+
+	r := &configLayers{
+		v:        viper.New(),
+		sources:  l.sources,
+		origins:  make(map[string]Source),
+		override: &l.options,
+	}
+	for _, src := range l.sources {
+		settings, err := src.Load(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("load config source %s: %w", src.Name(), err)
+		}
+		if err := r.v.MergeConfigMap(settings); err != nil {
+			return nil, fmt.Errorf("merge config source %s: %w", src.Name(), err)
+		}
+		for _, key := range settingKeys(settings) {
+			r.origins[key] = src
+		}
+	}
+	if err := l.options.bindOverrides(r.v); err != nil {
+		return nil, err
+	}
+	decrypted, err := l.options.decryptSecrets(r.v)
+	if err != nil {
+		return nil, err
+	}
+	r.decrypted = decrypted
+	return r, nil
+}
+
+// settingKeys list the dotted lower case leaf keys of settings
+func settingKeys(settings map[string]interface{}) []string {
+	v := viper.New()
+	_ = v.MergeConfigMap(settings)
+	return v.AllKeys()
+}
+
+// watch let every source signal its changes, a failing watch only disables that source's reload
+func (l *configLoader) watch() {
+	for _, src := range l.sources {
+		go func(src Source) {
+			if err := src.Watch(context.Background(), l.scheduleReload); err != nil {
+				fmt.Printf("config watcher of %s disabled:%s\n", src.Name(), err)
+			}
+		}(src)
+	}
+}
+
+// scheduleReload debounce the bursts of changes, editors often write a file several times
+func (l *configLoader) scheduleReload() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.timer != nil {
+		l.timer.Stop()
+	}
+	l.timer = time.AfterFunc(reloadDebounce, l.reload)
+}
+
+func (l *configLoader) reload() {
+	l.reloadMu.Lock()
+	defer l.reloadMu.Unlock()
+	layers, err := l.read()
+	if err == nil {
+		err = reloadConfig(layers.v, layers.origin)
+	}
+	if err != nil {
+		fmt.Printf("config reload rejected, keep the old one:%s\n", err)
+	}
+}
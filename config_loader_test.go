@@ -0,0 +1,54 @@
+package platform
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfigFile(t *testing.T, name, content string) {
+	assert.Nil(t, os.MkdirAll(filepath.Dir(name), os.ModePerm))
+	assert.Nil(t, os.WriteFile(name, []byte(content), 0644))
+}
+
+func TestConfigLoaderLayers(t *testing.T) {
+	wd, _ := os.Getwd()
+	assert.Nil(t, os.Chdir(t.TempDir()))
+	defer os.Chdir(wd)
+
+	writeConfigFile(t, "conf/base/main.yaml", "log:\n  level: info\n  director: log\nmysql:\n  path: base-host\n  port: \"3306\"\n")
+	writeConfigFile(t, "conf/testing/main.yaml", "include: [mysql.yaml]\nlog:\n  level: warn\nmysql:\n  username: root\n")
+	writeConfigFile(t, "conf/testing/mysql.yaml", "mysql:\n  path: include-host\n  username: include-user\n")
+	writeConfigFile(t, "conf/testing/main.local.json", `{"mysql": {"password": "local-pass"}}`)
+
+	l := newConfigLoader("testing", "main")
+	layers, err := l.read()
+	assert.Nil(t, err)
+	files := l.sources[0].(*FileSource)
+	assert.Len(t, files.Files(), 4)
+
+	var c Config
+	assert.Nil(t, layers.v.Unmarshal(&c))
+	assert.Equal(t, "warn", c.Log.Level)
+	assert.Equal(t, "log", c.Log.Director)
+	assert.Equal(t, "include-host", c.Mysql.Path)
+	assert.Equal(t, "3306", c.Mysql.Port)
+	// the including file wins over its includes
+	assert.Equal(t, "root", c.Mysql.Username)
+	assert.Equal(t, "local-pass", c.Mysql.Password)
+
+	assert.Equal(t, "conf/testing/mysql.yaml", layers.origin("mysql.path"))
+	assert.Equal(t, "conf/testing/main.local.json", layers.origin("mysql.password"))
+	assert.Equal(t, "conf/testing/main.yaml", layers.origin("redis.addr"))
+
+	assert.True(t, files.watched("conf/testing/mysql.yaml"))
+	assert.True(t, files.watched("conf/base/main.json"))
+	assert.False(t, files.watched("conf/base/main.local.yaml"))
+	assert.False(t, files.watched("conf/testing/other.yaml"))
+
+	writeConfigFile(t, "conf/testing/mysql.yaml", "include: [main.yaml]\n")
+	_, err = l.read()
+	assert.NotNil(t, err)
+}
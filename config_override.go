@@ -0,0 +1,144 @@
+package platform
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/chenxuan520/goweb-platform/utils"
+	"github.com/spf13/viper"
+)
+
+const (
+	sourceDefault = "default"
+	sourceFile    = "file"
+	sourceEnv     = "env"
+	sourceFlag    = "flag"
+)
+
+var envKeyReplacer = strings.NewReplacer(".", "_", "-", "_")
+
+// LoadOption tune LoadConfig
+type LoadOption func(o *loadOptions)
+
+type loadOptions struct {
+	envPrefix     string
+	overrides     map[string]string
+	secretKeyFile string
+	sources       []Source
+}
+
+// WithEnvPrefix let <PREFIX>_<SECTION>_<KEY> environment variables override the file,
+// e.g. APP_MYSQL_PASSWORD for mysql.password, dashes become underscores as well
+func WithEnvPrefix(prefix string) LoadOption {
+	return func(o *loadOptions) {
+		o.envPrefix = prefix
+	}
+}
+
+// WithOverrides apply key=value pairs, such as the --set flags, on top of file and env
+func WithOverrides(sets ...string) LoadOption {
+	return func(o *loadOptions) {
+		for _, set := range sets {
+			kv := strings.SplitN(set, "=", 2)
+			if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+				fmt.Printf("ignore config override %q, expect key=value\n", set)
+				continue
+			}
+			if o.overrides == nil {
+				o.overrides = make(map[string]string)
+			}
+			o.overrides[strings.ToLower(strings.TrimSpace(kv[0]))] = kv[1]
+		}
+	}
+}
+
+// bindOverrides wire env and flag overrides into v, viper then resolves file < env < flag
+func (o *loadOptions) bindOverrides(v *viper.Viper) error {
+	if o.envPrefix != "" {
+		v.SetEnvPrefix(o.envPrefix)
+		v.SetEnvKeyReplacer(envKeyReplacer)
+		v.AutomaticEnv()
+		// Unmarshal only sees keys viper knows of, bind the absent ones explicitly
+		for _, key := range configKeys(reflect.TypeOf(Config{}), "") {
+			if err := v.BindEnv(key); err != nil {
+				return err
+			}
+		}
+	}
+	for key, value := range o.overrides {
+		v.Set(key, value)
+	}
+	return nil
+}
+
+func (o *loadOptions) envName(key string) string {
+	return strings.ToUpper(o.envPrefix + "_" + envKeyReplacer.Replace(key))
+}
+
+// source tell where the effective value of key comes from
+func (o *loadOptions) source(v *viper.Viper, key string) string {
+	if _, ok := o.overrides[key]; ok {
+		return sourceFlag
+	}
+	if o.envPrefix != "" {
+		if _, ok := os.LookupEnv(o.envName(key)); ok {
+			return sourceEnv
+		}
+	}
+	if v.InConfig(key) {
+		return sourceFile
+	}
+	return sourceDefault
+}
+
+// printSources print every config key with its effective value and source,
+// the secret and the decrypted keys are masked
+func (o *loadOptions) printSources(v *viper.Viper, decrypted map[string]bool) {
+	keys := configKeys(reflect.TypeOf(Config{}), "")
+	for key := range o.overrides {
+		if !utils.IsContain(key, keys) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	fmt.Println("effective config (file < env < flag):")
+	for _, key := range keys {
+		value := fmt.Sprintf("%v", v.Get(key))
+		if (isSecretKey(key) || decrypted[key]) && value != "" {
+			value = secretMask
+		}
+		fmt.Printf("  %s = %s (%s)\n", key, value, o.source(v, key))
+	}
+}
+
+// configKeys list the dotted mapstructure keys of the struct type t
+func configKeys(t reflect.Type, prefix string) []string {
+	var keys []string
+	walkConfigFields(t, prefix, func(key string, field reflect.StructField) {
+		keys = append(keys, key)
+	})
+	return keys
+}
+
+// walkConfigFields call fn with the dotted mapstructure key of every leaf field of the struct type t
+func walkConfigFields(t reflect.Type, prefix string, fn func(key string, field reflect.StructField)) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		key := tag
+		if prefix != "" {
+			key = prefix + "." + tag
+		}
+		if field.Type.Kind() == reflect.Struct {
+			walkConfigFields(field.Type, key, fn)
+			continue
+		}
+		fn(key, field)
+	}
+}
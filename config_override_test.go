@@ -0,0 +1,35 @@
+package platform
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBindOverrides(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	assert.Nil(t, v.ReadConfig(strings.NewReader("mysql:\n  path: file-host\n  password: file-pass\nsystem:\n  addr: 8080\n")))
+	t.Setenv("APP_MYSQL_PASSWORD", "env-pass")
+	t.Setenv("APP_MYSQL_DB_NAME", "env-db")
+	t.Setenv("APP_SYSTEM_ADDR", "9090")
+
+	var options loadOptions
+	WithEnvPrefix("APP")(&options)
+	WithOverrides("system.addr=7070", "broken")(&options)
+	assert.Nil(t, options.bindOverrides(v))
+
+	var c Config
+	assert.Nil(t, v.Unmarshal(&c))
+	assert.Equal(t, "file-host", c.Mysql.Path)
+	assert.Equal(t, "env-pass", c.Mysql.Password)
+	assert.Equal(t, "env-db", c.Mysql.Dbname)
+	assert.Equal(t, 7070, c.System.Addr)
+
+	assert.Equal(t, sourceFile, options.source(v, "mysql.path"))
+	assert.Equal(t, sourceEnv, options.source(v, "mysql.password"))
+	assert.Equal(t, sourceFlag, options.source(v, "system.addr"))
+	assert.Equal(t, sourceDefault, options.source(v, "redis.addr"))
+}
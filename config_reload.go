@@ -0,0 +1,151 @@
+package platform
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/spf13/viper"
+)
+
+// SectionAll subscribe to a change of any section
+const SectionAll = "*"
+
+type changeSubscriber struct {
+	id      uint64
+	section string
+	fn      func(old, cur *Config)
+}
+
+// snapshot is an immutable view of one load, settings keep the raw values of every
+// top level key for the application sections
+type snapshot struct {
+	config   *Config
+	settings map[string]interface{}
+	sections map[string]reflect.Value
+}
+
+var (
+	// _snapshot hold the current *snapshot, a reload stores a new one instead of mutating it
+	_snapshot atomic.Value
+
+	subscribersMu    sync.Mutex
+	subscribers      []changeSubscriber
+	lastSubscriberID uint64
+)
+
+// OnChange call fn after a reload changed the given top level section, e.g. "log", "mysql"
+// or an application section, use SectionAll for any change. old and cur must be treated as read only.
+// The returned cancel remove the subscription, calling it again is a no-op
+func OnChange(section string, fn func(old, cur *Config)) (cancel func()) {
+	subscribersMu.Lock()
+	defer subscribersMu.Unlock()
+	lastSubscriberID++
+	id := lastSubscriberID
+	subscribers = append(subscribers, changeSubscriber{id: id, section: section, fn: fn})
+	return func() {
+		subscribersMu.Lock()
+		defer subscribersMu.Unlock()
+		for i, sub := range subscribers {
+			if sub.id == id {
+				subscribers = append(subscribers[:i:i], subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func currentSnapshot() *snapshot {
+	s, _ := _snapshot.Load().(*snapshot)
+	return s
+}
+
+// GetConfigModels return the current config snapshot, it must be treated as read only
+func GetConfigModels() *Config {
+	if s := currentSnapshot(); s != nil {
+		return s.config
+	}
+	return nil
+}
+
+// buildSnapshot decode and validate v, including the registered application sections,
+// origin name the file, env or flag of a key in the validation report and may be nil
+func buildSnapshot(v *viper.Viper, origin func(key string) string) (*snapshot, error) {
+	var c Config
+	if err := v.Unmarshal(&c); err != nil {
+		return nil, err
+	}
+	c.applyDefaults()
+	if err := c.validate(origin); err != nil {
+		return nil, err
+	}
+	s := &snapshot{config: &c, settings: v.AllSettings()}
+	sections, err := decodeSections(s.settings, origin)
+	if err != nil {
+		return nil, err
+	}
+	s.sections = sections
+	return s, nil
+}
+
+// storeSnapshot publish s and fill the registered application sections
+func storeSnapshot(s *snapshot) *snapshot {
+	old := currentSnapshot()
+	_snapshot.Store(s)
+	applySections(s.sections)
+	return old
+}
+
+// reloadConfig build a new snapshot from v, a config failing validation is rejected
+// and the old snapshot stays in use
+func reloadConfig(v *viper.Viper, origin func(key string) string) error {
+	s, err := buildSnapshot(v, origin)
+	if err != nil {
+		return err
+	}
+	old := storeSnapshot(s)
+	notifyChange(old, s)
+	return nil
+}
+
+func notifyChange(old, cur *snapshot) {
+	if old == nil {
+		return
+	}
+	subscribersMu.Lock()
+	subs := make([]changeSubscriber, len(subscribers))
+	copy(subs, subscribers)
+	subscribersMu.Unlock()
+
+	for _, sub := range subs {
+		if sectionChanged(old, cur, sub.section) {
+			callSubscriber(sub, old.config, cur.config)
+		}
+	}
+}
+
+// callSubscriber keep a panicking subscriber from killing the config watcher
+func callSubscriber(sub changeSubscriber, old, cur *Config) {
+	defer func() {
+		if err := recover(); err != nil {
+			fmt.Printf("config change subscriber of section %s panic:%v\n", sub.section, err)
+		}
+	}()
+	sub.fn(old, cur)
+}
+
+func sectionChanged(old, cur *snapshot, section string) bool {
+	if section == SectionAll {
+		return !reflect.DeepEqual(old.config, cur.config) || !reflect.DeepEqual(old.settings, cur.settings)
+	}
+	ov, nv := reflect.ValueOf(old.config).Elem(), reflect.ValueOf(cur.config).Elem()
+	for i := 0; i < ov.NumField(); i++ {
+		tag := strings.Split(ov.Type().Field(i).Tag.Get("mapstructure"), ",")[0]
+		if tag == section {
+			return !reflect.DeepEqual(ov.Field(i).Interface(), nv.Field(i).Interface())
+		}
+	}
+	return !reflect.DeepEqual(old.settings[section], cur.settings[section])
+}
@@ -0,0 +1,77 @@
+package platform
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// resetConfigState restore the global snapshot, subscribers and sections once the test ends
+func resetConfigState(t *testing.T) {
+	old := currentSnapshot()
+	subscribersMu.Lock()
+	subs := append([]changeSubscriber(nil), subscribers...)
+	subscribersMu.Unlock()
+	sectionsMu.Lock()
+	registered := make(map[string]interface{}, len(sections))
+	for name, ptr := range sections {
+		registered[name] = ptr
+	}
+	sectionsMu.Unlock()
+
+	t.Cleanup(func() {
+		_snapshot.Store(old)
+		subscribersMu.Lock()
+		subscribers = subs
+		subscribersMu.Unlock()
+		sectionsMu.Lock()
+		sections = registered
+		sectionsMu.Unlock()
+	})
+}
+
+// newValidViper return a viper holding the keys a config requires
+func newValidViper() *viper.Viper {
+	v := viper.New()
+	v.Set("system.addr", 8080)
+	v.Set("log.director", "log")
+	return v
+}
+
+func TestReloadConfig(t *testing.T) {
+	resetConfigState(t)
+	v := newValidViper()
+	v.Set("log.level", "info")
+	assert.Nil(t, reloadConfig(v, nil))
+	first := GetConfigModels()
+
+	var logChanged, mysqlChanged int
+	OnChange("log", func(old, cur *Config) {
+		logChanged++
+		assert.Equal(t, "info", old.Log.Level)
+		assert.Equal(t, "debug", cur.Log.Level)
+	})
+	cancel := OnChange("mysql", func(old, cur *Config) {
+		mysqlChanged++
+	})
+
+	v.Set("log.level", "debug")
+	assert.Nil(t, reloadConfig(v, nil))
+	assert.Equal(t, 1, logChanged)
+	assert.Equal(t, 0, mysqlChanged)
+	// the old snapshot is never mutated
+	assert.Equal(t, "info", first.Log.Level)
+
+	// a cancelled subscriber is not called anymore
+	cancel()
+	cancel()
+	v.Set("mysql.path", "db")
+	assert.Nil(t, reloadConfig(v, nil))
+	assert.Equal(t, 0, mysqlChanged)
+	assert.Equal(t, 1, logChanged)
+
+	v.Set("system.addr", 70000)
+	assert.NotNil(t, reloadConfig(v, nil))
+	assert.Equal(t, 8080, GetConfigModels().System.Addr)
+}
@@ -0,0 +1,214 @@
+package platform
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// SecretKeyEnv hold the base64 AES key decrypting the ENC(...) values
+	SecretKeyEnv = "CONFIG_SECRET_KEY"
+	// SecretKeyFileEnv name a file holding the base64 AES key, used when SecretKeyEnv is empty
+	SecretKeyFileEnv = "CONFIG_SECRET_KEY_FILE"
+
+	secretPrefix = "ENC("
+	secretSuffix = ")"
+	secretMask   = "******"
+)
+
+// WithSecretKeyFile read the key decrypting ENC(...) values from file instead of the environment
+func WithSecretKeyFile(file string) LoadOption {
+	return func(o *loadOptions) {
+		o.secretKeyFile = file
+	}
+}
+
+// IsEncrypted tell whether value is an ENC(...) secret
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, secretPrefix) && strings.HasSuffix(value, secretSuffix)
+}
+
+// GenerateSecretKey return a new random base64 AES-256 key
+func GenerateSecretKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// LoadSecretKey read the AES key from keyFile, or else from SecretKeyEnv then SecretKeyFileEnv
+func LoadSecretKey(keyFile string) ([]byte, error) {
+	encoded := ""
+	if keyFile == "" {
+		encoded = os.Getenv(SecretKeyEnv)
+		keyFile = os.Getenv(SecretKeyFileEnv)
+	}
+	if encoded == "" {
+		if keyFile == "" {
+			return nil, fmt.Errorf("no secret key, set %s or %s", SecretKeyEnv, SecretKeyFileEnv)
+		}
+		content, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read secret key file: %w", err)
+		}
+		encoded = string(content)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("secret key is not base64: %w", err)
+	}
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	}
+	return nil, fmt.Errorf("secret key must be 16, 24 or 32 bytes, got %d", len(key))
+}
+
+// EncryptSecret seal plaintext with AES-GCM into ENC(base64(nonce|ciphertext))
+func EncryptSecret(key []byte, plaintext string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return secretPrefix + base64.StdEncoding.EncodeToString(sealed) + secretSuffix, nil
+}
+
+// DecryptSecret open an ENC(...) value sealed by EncryptSecret
+func DecryptSecret(key []byte, value string) (string, error) {
+	if !IsEncrypted(value) {
+		return "", errors.New("value is not wrapped in ENC(...)")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(value[len(secretPrefix) : len(value)-len(secretSuffix)])
+	if err != nil {
+		return "", fmt.Errorf("secret is not base64: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("secret is too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// decryptSecrets replace every ENC(...) value of v, the key is only loaded when one is found.
+// It return the decrypted keys, so they are masked when printed
+func (o *loadOptions) decryptSecrets(v *viper.Viper) (map[string]bool, error) {
+	var key []byte
+	decrypted := make(map[string]bool)
+	for _, name := range v.AllKeys() {
+		value, ok := v.Get(name).(string)
+		if !ok || !IsEncrypted(value) {
+			continue
+		}
+		if key == nil {
+			var err error
+			if key, err = LoadSecretKey(o.secretKeyFile); err != nil {
+				return nil, fmt.Errorf("config key %s is encrypted: %w", name, err)
+			}
+		}
+		plaintext, err := DecryptSecret(key, value)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt config key %s: %w", name, err)
+		}
+		v.Set(name, plaintext)
+		decrypted[name] = true
+	}
+	return decrypted, nil
+}
+
+// isSecretKey tell whether the value of key must never be printed, the config fields
+// tagged secret:"true" and, for the application keys, names ending in password or token
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	if _, ok := secretConfigKeys[key]; ok {
+		return true
+	}
+	return strings.HasSuffix(key, "password") || strings.HasSuffix(key, "token")
+}
+
+var secretConfigKeys = func() map[string]struct{} {
+	keys := make(map[string]struct{})
+	walkConfigFields(reflect.TypeOf(Config{}), "", func(key string, field reflect.StructField) {
+		if field.Tag.Get("secret") == "true" {
+			keys[key] = struct{}{}
+		}
+	})
+	return keys
+}()
+
+// Redacted return a copy of c with the secret fields masked
+func (c Config) Redacted() Config {
+	return c.redactedWith(nil)
+}
+
+// redactedWith return a copy of c with the secret fields and the given keys masked,
+// such as the keys decrypted from an ENC(...) value
+func (c Config) redactedWith(keys map[string]bool) Config {
+	redactStruct(reflect.ValueOf(&c).Elem(), "", keys)
+	return c
+}
+
+func redactStruct(rv reflect.Value, prefix string, keys map[string]bool) {
+	for i := 0; i < rv.NumField(); i++ {
+		field, value := rv.Type().Field(i), rv.Field(i)
+		key := strings.ToLower(strings.Split(field.Tag.Get("mapstructure"), ",")[0])
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch {
+		case field.Type.Kind() == reflect.Struct:
+			redactStruct(value, key, keys)
+		case (field.Tag.Get("secret") == "true" || keys[key]) && field.Type.Kind() == reflect.String && value.String() != "":
+			value.SetString(secretMask)
+		}
+	}
+}
+
+// formatConfig print c as %#v does, with the secret fields and the decrypted keys masked
+func formatConfig(c Config, decrypted map[string]bool) string {
+	return fmt.Sprintf("%#v", redactedConfig(c.redactedWith(decrypted)))
+}
+
+// redactedConfig has no methods, so formatting it does not recurse into String
+type redactedConfig Config
+
+// String print the config with the secret fields masked
+func (c Config) String() string {
+	return fmt.Sprintf("%+v", redactedConfig(c.Redacted()))
+}
+
+// GoString keep %#v from printing the secret fields
+func (c Config) GoString() string {
+	return formatConfig(c, nil)
+}
@@ -0,0 +1,78 @@
+package platform
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecret(t *testing.T) {
+	encoded, err := GenerateSecretKey()
+	assert.Nil(t, err)
+	t.Setenv(SecretKeyEnv, encoded)
+	key, err := LoadSecretKey("")
+	assert.Nil(t, err)
+
+	sealed, err := EncryptSecret(key, "db-pass")
+	assert.Nil(t, err)
+	assert.True(t, IsEncrypted(sealed))
+	plain, err := DecryptSecret(key, sealed)
+	assert.Nil(t, err)
+	assert.Equal(t, "db-pass", plain)
+
+	other, _ := GenerateSecretKey()
+	t.Setenv(SecretKeyEnv, other)
+	otherKey, _ := LoadSecretKey("")
+	_, err = DecryptSecret(otherKey, sealed)
+	assert.NotNil(t, err)
+
+	t.Setenv(SecretKeyEnv, encoded)
+	v := viper.New()
+	v.Set("mysql.password", sealed)
+	v.Set("mysql.username", "root")
+	var options loadOptions
+	decrypted, err := options.decryptSecrets(v)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]bool{"mysql.password": true}, decrypted)
+	assert.Equal(t, "db-pass", v.GetString("mysql.password"))
+}
+
+func TestConfigRedacted(t *testing.T) {
+	c := Config{Mysql: Mysql{Username: "root", Password: "db-pass"}, System: System{AdminToken: "admin"}}
+	for _, printed := range []string{fmt.Sprintf("%#v", c), fmt.Sprintf("%v", c), c.String()} {
+		assert.NotContains(t, printed, "db-pass")
+		assert.NotContains(t, printed, "admin")
+		assert.Contains(t, printed, "root")
+	}
+	assert.Equal(t, "db-pass", c.Mysql.Password)
+	assert.True(t, isSecretKey("redis.password"))
+	assert.False(t, isSecretKey("redis.addr"))
+}
+
+func TestFormatConfig(t *testing.T) {
+	encoded, err := GenerateSecretKey()
+	assert.Nil(t, err)
+	t.Setenv(SecretKeyEnv, encoded)
+	key, err := LoadSecretKey("")
+	assert.Nil(t, err)
+	sealed, err := EncryptSecret(key, "db-user")
+	assert.Nil(t, err)
+
+	v := viper.New()
+	v.Set("mysql.username", sealed)
+	v.Set("mysql.db-name", "app")
+	var options loadOptions
+	decrypted, err := options.decryptSecrets(v)
+	assert.Nil(t, err)
+	var c Config
+	assert.Nil(t, v.Unmarshal(&c))
+	assert.Equal(t, "db-user", c.Mysql.Username)
+
+	// a field is masked once its value was encrypted, whatever its name
+	printed := formatConfig(c, decrypted)
+	assert.NotContains(t, printed, "db-user")
+	assert.Contains(t, printed, "app")
+	assert.Contains(t, fmt.Sprintf("%#v", c), "db-user")
+}
@@ -0,0 +1,115 @@
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// Validator is implemented by application sections which check their own values,
+// a section failing it rejects the load or reload
+type Validator interface {
+	Validate() error
+}
+
+var (
+	sectionsMu sync.Mutex
+	// sections map a top level key to the pointer registered by the application
+	sections = make(map[string]interface{})
+)
+
+// RegisterSection decode the top level key name into ptr, now if the config is already
+// loaded and again on every reload. ptr is written by the config watcher, so code reading
+// it concurrently should rather use LoadSection or OnChange(name, ...)
+func RegisterSection(name string, ptr interface{}) error {
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("config section must be a non nil pointer")
+	}
+	sectionsMu.Lock()
+	sections[name] = ptr
+	sectionsMu.Unlock()
+
+	s := currentSnapshot()
+	if s == nil {
+		return nil
+	}
+	value, err := decodeSection(s.settings, name, rv.Type().Elem(), nil)
+	if err != nil {
+		return err
+	}
+	rv.Elem().Set(value)
+	return nil
+}
+
+// LoadSection decode the top level key of the current config into a new T
+func LoadSection[T any](key string) (T, error) {
+	var out T
+	s := currentSnapshot()
+	if s == nil {
+		return out, errors.New("config is not loaded")
+	}
+	value, err := decodeSection(s.settings, key, reflect.TypeOf(&out).Elem(), nil)
+	if err != nil {
+		return out, err
+	}
+	return value.Interface().(T), nil
+}
+
+// decodeSection decode settings[name] into a new typ, then check its validate tags and Validator
+func decodeSection(settings map[string]interface{}, name string, typ reflect.Type, origin func(key string) string) (reflect.Value, error) {
+	ptr := reflect.New(typ)
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+		WeaklyTypedInput: true,
+		Result:           ptr.Interface(),
+	})
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if err := decoder.Decode(settings[name]); err != nil {
+		return reflect.Value{}, fmt.Errorf("config section %s: %w", name, err)
+	}
+	if typ.Kind() == reflect.Struct {
+		if errs := structErrors(name, ptr.Interface(), origin); len(errs) > 0 {
+			return reflect.Value{}, &ValidationError{Errors: errs}
+		}
+	}
+	if validator, ok := ptr.Interface().(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			return reflect.Value{}, fmt.Errorf("config section %s: %w", name, err)
+		}
+	}
+	return ptr.Elem(), nil
+}
+
+// decodeSections decode every registered section without touching the targets yet
+func decodeSections(settings map[string]interface{}, origin func(key string) string) (map[string]reflect.Value, error) {
+	sectionsMu.Lock()
+	defer sectionsMu.Unlock()
+	values := make(map[string]reflect.Value, len(sections))
+	for name, ptr := range sections {
+		value, err := decodeSection(settings, name, reflect.TypeOf(ptr).Elem(), origin)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+func applySections(values map[string]reflect.Value) {
+	sectionsMu.Lock()
+	defer sectionsMu.Unlock()
+	for name, value := range values {
+		if ptr, ok := sections[name]; ok {
+			reflect.ValueOf(ptr).Elem().Set(value)
+		}
+	}
+}
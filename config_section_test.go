@@ -0,0 +1,52 @@
+package platform
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type thirdParty struct {
+	AppKey  string        `mapstructure:"app-key"`
+	Timeout time.Duration `mapstructure:"timeout"`
+	Limits  []int         `mapstructure:"limits"`
+}
+
+func (t *thirdParty) Validate() error {
+	if t.AppKey == "" {
+		return errors.New("app-key is required")
+	}
+	return nil
+}
+
+func TestConfigSection(t *testing.T) {
+	resetConfigState(t)
+	v := newValidViper()
+	v.Set("third-party", map[string]interface{}{"app-key": "k1", "timeout": "3s", "limits": []int{1, 2}})
+	assert.Nil(t, reloadConfig(v, nil))
+
+	var registered thirdParty
+	assert.Nil(t, RegisterSection("third-party", &registered))
+	assert.Equal(t, "k1", registered.AppKey)
+	assert.Equal(t, 3*time.Second, registered.Timeout)
+
+	changed := 0
+	OnChange("third-party", func(old, cur *Config) {
+		changed++
+	})
+	v.Set("third-party", map[string]interface{}{"app-key": "k2", "timeout": "5s"})
+	assert.Nil(t, reloadConfig(v, nil))
+	assert.Equal(t, 1, changed)
+	assert.Equal(t, "k2", registered.AppKey)
+
+	loaded, err := LoadSection[thirdParty]("third-party")
+	assert.Nil(t, err)
+	assert.Equal(t, 5*time.Second, loaded.Timeout)
+
+	// a section failing validation rejects the whole reload
+	v.Set("third-party", map[string]interface{}{"timeout": "1s"})
+	assert.NotNil(t, reloadConfig(v, nil))
+	assert.Equal(t, "k2", registered.AppKey)
+}
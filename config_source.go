@@ -0,0 +1,42 @@
+package platform
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/spf13/viper"
+)
+
+// Source supply one layer of the config, LoadConfig deep merges its sources in order
+// so the later ones win
+type Source interface {
+	// Name identify the source in logs
+	Name() string
+	// Load return the nested settings of the source, as decoded from a json or yaml document
+	Load(ctx context.Context) (map[string]interface{}, error)
+	// Watch call onChange whenever the settings may have changed, until ctx is done
+	Watch(ctx context.Context, onChange func()) error
+}
+
+// originSource is a Source telling which of its parts a key comes from, such as a file
+type originSource interface {
+	Origin(key string) string
+}
+
+// WithSources replace the files of LoadConfig by sources, merged in order.
+// Pass NewFileSource first to layer remote sources on top of the files
+func WithSources(sources ...Source) LoadOption {
+	return func(o *loadOptions) {
+		o.sources = append(o.sources, sources...)
+	}
+}
+
+// decodeSettings parse a json, yaml or ini document into nested settings
+func decodeSettings(format string, content []byte) (map[string]interface{}, error) {
+	v := viper.New()
+	v.SetConfigType(format)
+	if err := v.ReadConfig(bytes.NewReader(content)); err != nil {
+		return nil, err
+	}
+	return v.AllSettings(), nil
+}
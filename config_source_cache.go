@@ -0,0 +1,83 @@
+package platform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// cachedSource keep the last settings of a remote source in a local file
+type cachedSource struct {
+	Source
+	file string
+
+	mu        sync.Mutex
+	fromCache bool
+}
+
+// CachedSource save every successful load of src to file and fall back to it when src is
+// unreachable, so a replica still starts, with the last known config, during an outage
+func CachedSource(src Source, file string) Source {
+	return &cachedSource{Source: src, file: file}
+}
+
+func (s *cachedSource) Load(ctx context.Context) (map[string]interface{}, error) {
+	settings, err := s.Source.Load(ctx)
+	if err == nil {
+		s.setFromCache(false)
+		if err := s.save(settings); err != nil {
+			fmt.Printf("cache config of %s failed:%s\n", s.Name(), err)
+		}
+		return settings, nil
+	}
+	content, cacheErr := os.ReadFile(s.file)
+	if cacheErr != nil {
+		return nil, err
+	}
+	var cached map[string]interface{}
+	if cacheErr := json.Unmarshal(content, &cached); cacheErr != nil {
+		return nil, fmt.Errorf("%w, and the cache %s is broken: %s", err, s.file, cacheErr)
+	}
+	fmt.Printf("config source %s unreachable, use the cache %s:%s\n", s.Name(), s.file, err)
+	s.setFromCache(true)
+	return cached, nil
+}
+
+// Origin name the cache file when the last load fell back to it
+func (s *cachedSource) Origin(key string) string {
+	s.mu.Lock()
+	fromCache := s.fromCache
+	s.mu.Unlock()
+	if fromCache {
+		return s.file
+	}
+	if o, ok := s.Source.(originSource); ok {
+		return o.Origin(key)
+	}
+	return s.Name()
+}
+
+func (s *cachedSource) setFromCache(fromCache bool) {
+	s.mu.Lock()
+	s.fromCache = fromCache
+	s.mu.Unlock()
+}
+
+// save write the cache through a temporary file, a crash never leaves half of it
+func (s *cachedSource) save(settings map[string]interface{}) error {
+	content, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.file), os.ModePerm); err != nil {
+		return err
+	}
+	tmp := s.file + ".tmp"
+	if err := os.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.file)
+}
@@ -0,0 +1,226 @@
+package platform
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/chenxuan520/goweb-platform/utils"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+const (
+	// baseEnv hold the settings shared by every environment
+	baseEnv = "base"
+	// localSuffix mark the gitignored per machine override, e.g. main.local.yaml
+	localSuffix = ".local"
+	includeKey  = "include"
+)
+
+// FileSource merge conf/base/<name>, conf/<env>/<name> and conf/<env>/<name>.local,
+// each file may pull other files with include. It is the default source of LoadConfig
+type FileSource struct {
+	env  string
+	name string
+
+	mu      sync.Mutex
+	files   []string
+	envFile string
+	origins map[string]string
+	watcher *fsnotify.Watcher
+}
+
+// NewFileSource read the <name> config files of env
+func NewFileSource(env, name string) *FileSource {
+	return &FileSource{env: env, name: name}
+}
+
+// findConfigFile return the first <dir>/<name>.<ext> following autoLoadLocalConfigs, empty if none
+func findConfigFile(dir, name string) string {
+	for _, registerExt := range autoLoadLocalConfigs {
+		confPath := path.Join(dir, name+registerExt)
+		if utils.Exists(confPath) {
+			return confPath
+		}
+	}
+	return ""
+}
+
+// Name list the files of the last load, from the lowest to the highest precedence
+func (s *FileSource) Name() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.files) == 0 {
+		return path.Join(nameSpace, s.env, s.name)
+	}
+	return strings.Join(s.files, " < ")
+}
+
+// Origin return the file which key comes from, the env file for a key set nowhere
+func (s *FileSource) Origin(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if file, ok := s.origins[key]; ok {
+		return file
+	}
+	return s.envFile
+}
+
+// Files return the files of the last load
+func (s *FileSource) Files() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.files...)
+}
+
+func (s *FileSource) Load(ctx context.Context) (map[string]interface{}, error) {
+	envDir := path.Join(nameSpace, s.env)
+	envFile := findConfigFile(envDir, s.name)
+	if envFile == "" {
+		return nil, fmt.Errorf("no %s config found in %s, tried %v", s.name, envDir, autoLoadLocalConfigs)
+	}
+	layers := []string{
+		findConfigFile(path.Join(nameSpace, baseEnv), s.name),
+		envFile,
+		findConfigFile(envDir, s.name+localSuffix),
+	}
+
+	r := &fileLayers{v: viper.New(), origins: make(map[string]string)}
+	for _, layer := range layers {
+		if layer == "" {
+			continue
+		}
+		if err := r.mergeConfigFile(layer, map[string]bool{}); err != nil {
+			return nil, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files, s.envFile, s.origins = r.files, filepath.Clean(envFile), r.origins
+	s.watchDirs()
+	return r.v.AllSettings(), nil
+}
+
+// fileLayers is the result of merging the files once
+type fileLayers struct {
+	v       *viper.Viper
+	files   []string
+	origins map[string]string
+}
+
+// mergeConfigFile deep merge the includes of file, then file itself, into r.v
+func (r *fileLayers) mergeConfigFile(file string, visiting map[string]bool) error {
+	file = filepath.Clean(file)
+	if visiting[file] {
+		return fmt.Errorf("config include cycle at %s", file)
+	}
+	visiting[file] = true
+	defer delete(visiting, file)
+
+	fv := viper.New()
+	fv.SetConfigFile(file)
+	fv.SetConfigType(utils.Ext(file))
+	if err := fv.ReadInConfig(); err != nil {
+		return fmt.Errorf("read config file %s: %w", file, err)
+	}
+	for _, include := range fv.GetStringSlice(includeKey) {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(file), include)
+		}
+		if err := r.mergeConfigFile(include, visiting); err != nil {
+			return err
+		}
+	}
+	settings := fv.AllSettings()
+	delete(settings, includeKey)
+	if err := r.v.MergeConfigMap(settings); err != nil {
+		return fmt.Errorf("merge config file %s: %w", file, err)
+	}
+	for _, key := range fv.AllKeys() {
+		r.origins[key] = file
+	}
+	r.files = append(r.files, file)
+	return nil
+}
+
+// watched tell whether a change of name can affect the merged config, the caller holds s.mu
+func (s *FileSource) watched(name string) bool {
+	name = filepath.Clean(name)
+	for _, file := range s.files {
+		if file == name {
+			return true
+		}
+	}
+	dir, base := filepath.Dir(name), filepath.Base(name)
+	ext := filepath.Ext(base)
+	if !utils.IsContain(ext, autoLoadLocalConfigs) {
+		return false
+	}
+	switch strings.TrimSuffix(base, ext) {
+	case s.name:
+		return dir == filepath.Join(nameSpace, baseEnv) || dir == filepath.Join(nameSpace, s.env)
+	case s.name + localSuffix:
+		return dir == filepath.Join(nameSpace, s.env)
+	}
+	return false
+}
+
+// Watch signal when any layer or include changes, editors often replace files
+// instead of writing them so the directories are watched
+func (s *FileSource) Watch(ctx context.Context, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	_ = watcher.Add(filepath.Join(nameSpace, baseEnv))
+	_ = watcher.Add(filepath.Join(nameSpace, s.env))
+	s.mu.Lock()
+	s.watcher = watcher
+	s.watchDirs()
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.watcher = nil
+		s.mu.Unlock()
+	}()
+
+	for {
+		select {
+		case e, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			s.mu.Lock()
+			watched := s.watched(e.Name)
+			s.mu.Unlock()
+			if !watched || e.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 {
+				continue
+			}
+			fmt.Println("config file changed:", e.Name)
+			onChange()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Println("config watcher error:", err)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// watchDirs add the directories of included files, the caller holds s.mu
+func (s *FileSource) watchDirs() {
+	if s.watcher == nil {
+		return
+	}
+	for _, file := range s.files {
+		_ = s.watcher.Add(filepath.Dir(file))
+	}
+}
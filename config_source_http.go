@@ -0,0 +1,141 @@
+package platform
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const httpSourceTimeout = 10 * time.Second
+
+// HTTPSource poll a json or yaml document from URL, the ETag of the last response is sent
+// back with If-None-Match so an unchanged config costs a 304
+type HTTPSource struct {
+	URL string
+	// Format is json or yaml, guessed from the Content-Type then the URL when empty
+	Format   string
+	Interval time.Duration
+	Header   http.Header
+	Client   *http.Client
+
+	mu       sync.Mutex
+	etag     string
+	body     []byte
+	settings map[string]interface{}
+}
+
+// NewHTTPSource poll url every interval
+func NewHTTPSource(url string, interval time.Duration) *HTTPSource {
+	return &HTTPSource{
+		URL:      url,
+		Interval: interval,
+		Header:   make(http.Header),
+		Client:   &http.Client{Timeout: httpSourceTimeout},
+	}
+}
+
+func (s *HTTPSource) Name() string {
+	return s.URL
+}
+
+func (s *HTTPSource) Load(ctx context.Context) (map[string]interface{}, error) {
+	settings, _, err := s.fetch(ctx)
+	return settings, err
+}
+
+// Watch poll URL every Interval and signal when the document changed
+func (s *HTTPSource) Watch(ctx context.Context, onChange func()) error {
+	if s.Interval <= 0 {
+		return fmt.Errorf("poll interval %s is not positive", s.Interval)
+	}
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	failing := false
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+		_, changed, err := s.fetch(ctx)
+		if err != nil {
+			// print the first failure only, the poll goes on until the endpoint is back
+			if !failing {
+				fmt.Printf("poll config %s failed:%s\n", s.URL, err)
+			}
+			failing = true
+			continue
+		}
+		failing = false
+		if changed {
+			onChange()
+		}
+	}
+}
+
+// fetch get the document, changed tell whether it differs from the one of the last fetch
+func (s *HTTPSource) fetch(ctx context.Context) (map[string]interface{}, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	for key, values := range s.Header {
+		req.Header[key] = values
+	}
+	s.mu.Lock()
+	if s.etag != "" && s.settings != nil {
+		req.Header.Set("If-None-Match", s.etag)
+	}
+	s.mu.Unlock()
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if resp.StatusCode == http.StatusNotModified && s.settings != nil {
+		return s.settings, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	if s.settings != nil && bytes.Equal(body, s.body) {
+		s.etag = resp.Header.Get("ETag")
+		return s.settings, false, nil
+	}
+	settings, err := decodeSettings(s.format(resp.Header.Get("Content-Type")), body)
+	if err != nil {
+		return nil, false, fmt.Errorf("decode %s: %w", s.URL, err)
+	}
+	s.etag, s.body, s.settings = resp.Header.Get("ETag"), body, settings
+	return settings, true, nil
+}
+
+func (s *HTTPSource) format(contentType string) string {
+	if s.Format != "" {
+		return s.Format
+	}
+	switch {
+	case strings.Contains(contentType, "yaml"):
+		return "yaml"
+	case strings.Contains(contentType, "json"):
+		return "json"
+	}
+	if ext := strings.TrimPrefix(path.Ext(strings.SplitN(s.URL, "?", 2)[0]), "."); ext == "yaml" || ext == "yml" {
+		return "yaml"
+	}
+	return "json"
+}
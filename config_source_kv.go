@@ -0,0 +1,138 @@
+package platform
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// KVStore is the part of an etcd like key value store a KVSource needs. The etcd clientv3
+// fits it with a Get and a Watch of the prefix using clientv3.WithPrefix()
+type KVStore interface {
+	// List return every key under prefix with its value
+	List(ctx context.Context, prefix string) (map[string]string, error)
+	// Watch send on the returned channel whenever a key under prefix changes,
+	// the channel is closed once ctx is done
+	Watch(ctx context.Context, prefix string) (<-chan struct{}, error)
+}
+
+// KVSource read one config key per store key, <prefix>/mysql/path holding mysql.path
+type KVSource struct {
+	store  KVStore
+	prefix string
+}
+
+// NewKVSource read the keys of store under prefix
+func NewKVSource(store KVStore, prefix string) *KVSource {
+	return &KVSource{store: store, prefix: strings.TrimSuffix(prefix, "/") + "/"}
+}
+
+func (s *KVSource) Name() string {
+	return "kv " + s.prefix
+}
+
+// Origin return the store key of key
+func (s *KVSource) Origin(key string) string {
+	return "kv " + s.prefix + strings.ReplaceAll(key, ".", "/")
+}
+
+func (s *KVSource) Load(ctx context.Context) (map[string]interface{}, error) {
+	kvs, err := s.store.List(ctx, s.prefix)
+	if err != nil {
+		return nil, err
+	}
+	settings := make(map[string]interface{})
+	for key, value := range kvs {
+		parts := strings.Split(strings.Trim(strings.TrimPrefix(key, s.prefix), "/"), "/")
+		if len(parts) == 0 || parts[0] == "" {
+			continue
+		}
+		node := settings
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := node[part].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				node[part] = child
+			}
+			node = child
+		}
+		node[parts[len(parts)-1]] = value
+	}
+	return settings, nil
+}
+
+func (s *KVSource) Watch(ctx context.Context, onChange func()) error {
+	events, err := s.store.Watch(ctx, s.prefix)
+	if err != nil {
+		return err
+	}
+	for range events {
+		onChange()
+	}
+	return nil
+}
+
+// MemoryKVStore is an in process KVStore, a stand-in for etcd in tests and local runs
+type MemoryKVStore struct {
+	mu       sync.Mutex
+	data     map[string]string
+	watchers map[chan struct{}]string
+}
+
+func NewMemoryKVStore() *MemoryKVStore {
+	return &MemoryKVStore{data: make(map[string]string), watchers: make(map[chan struct{}]string)}
+}
+
+func (m *MemoryKVStore) Put(key, value string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[key] = value
+	m.notify(key)
+}
+
+func (m *MemoryKVStore) Delete(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data, key)
+	m.notify(key)
+}
+
+// notify wake the watchers of key without blocking, the caller holds m.mu
+func (m *MemoryKVStore) notify(key string) {
+	for ch, prefix := range m.watchers {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (m *MemoryKVStore) List(ctx context.Context, prefix string) (map[string]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kvs := make(map[string]string)
+	for key, value := range m.data {
+		if strings.HasPrefix(key, prefix) {
+			kvs[key] = value
+		}
+	}
+	return kvs, nil
+}
+
+func (m *MemoryKVStore) Watch(ctx context.Context, prefix string) (<-chan struct{}, error) {
+	ch := make(chan struct{}, 1)
+	m.mu.Lock()
+	m.watchers[ch] = prefix
+	m.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		m.mu.Lock()
+		delete(m.watchers, ch)
+		close(ch)
+		m.mu.Unlock()
+	}()
+	return ch, nil
+}
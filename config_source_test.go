@@ -0,0 +1,110 @@
+package platform
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPSource(t *testing.T) {
+	var requests, notModified int32
+	body := atomic.Value{}
+	body.Store("system:\n  addr: 8080\n")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		etag := fmt.Sprintf(`"%x"`, sha256.Sum256([]byte(body.Load().(string))))
+		if r.Header.Get("If-None-Match") == etag {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "application/yaml")
+		_, _ = w.Write([]byte(body.Load().(string)))
+	}))
+	defer srv.Close()
+
+	src := NewHTTPSource(srv.URL, 10*time.Millisecond)
+	settings, err := src.Load(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 8080, settings["system"].(map[string]interface{})["addr"])
+
+	_, changed, err := src.fetch(context.Background())
+	assert.Nil(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&notModified))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan struct{}, 1)
+	go src.Watch(ctx, func() { changes <- struct{}{} })
+	body.Store("system:\n  addr: 9090\n")
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("change of the document not detected")
+	}
+	settings, _ = src.Load(context.Background())
+	assert.Equal(t, 9090, settings["system"].(map[string]interface{})["addr"])
+}
+
+func TestKVSource(t *testing.T) {
+	store := NewMemoryKVStore()
+	store.Put("/app/system/addr", "8080")
+	store.Put("/app/mysql/path", "kv-host")
+	store.Put("/other/mysql/path", "other-host")
+
+	src := NewKVSource(store, "/app")
+	settings, err := src.Load(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"system": map[string]interface{}{"addr": "8080"},
+		"mysql":  map[string]interface{}{"path": "kv-host"},
+	}, settings)
+	assert.Equal(t, "kv /app/mysql/path", src.Origin("mysql.path"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		_ = src.Watch(ctx, func() { changes <- struct{}{} })
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	store.Put("/app/mysql/path", "new-host")
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("change of the store not detected")
+	}
+	cancel()
+	<-done
+}
+
+func TestCachedSource(t *testing.T) {
+	store := NewMemoryKVStore()
+	store.Put("/app/system/addr", "8080")
+	cache := filepath.Join(t.TempDir(), "main.remote.json")
+
+	src := CachedSource(NewKVSource(store, "/app"), cache)
+	settings, err := src.Load(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, "kv /app/system/addr", src.(originSource).Origin("system.addr"))
+
+	unreachable := CachedSource(NewHTTPSource("http://127.0.0.1:1/config.json", time.Second), cache)
+	cached, err := unreachable.Load(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, settings, cached)
+	assert.Equal(t, cache, unreachable.(originSource).Origin("system.addr"))
+
+	_, err = CachedSource(NewHTTPSource("http://127.0.0.1:1/config.json", time.Second), cache+".missing").Load(context.Background())
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,154 @@
+package platform
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var (
+	validateOnce sync.Once
+	_validate    *validator.Validate
+)
+
+// getValidate build the shared validator, field names in reports are the mapstructure keys
+func getValidate() *validator.Validate {
+	validateOnce.Do(func() {
+		_validate = validator.New()
+		_validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("mapstructure"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return strings.ToLower(field.Name)
+			}
+			return name
+		})
+		_ = _validate.RegisterValidation("cors_origin", validCorsOrigin)
+		_validate.RegisterStructValidation(validCorsPolicy, CorsPolicy{})
+	})
+	return _validate
+}
+
+// validCorsPolicy reject credentials with the * origin, browsers refuse this pair
+func validCorsPolicy(sl validator.StructLevel) {
+	p := sl.Current().Interface().(CorsPolicy)
+	if !p.AllowCredentials {
+		return
+	}
+	for _, origin := range p.AllowOrigins {
+		if origin == "*" {
+			sl.ReportError(p.AllowCredentials, "allow-credentials", "AllowCredentials", "cors_credentials", "")
+			return
+		}
+	}
+}
+
+// validCorsOrigin accept *, a ~ prefixed regexp compiling, or an origin with a scheme.
+// The regexp must match the whole origin
+func validCorsOrigin(fl validator.FieldLevel) bool {
+	origin := fl.Field().String()
+	switch {
+	case origin == "*":
+		return true
+	case strings.HasPrefix(origin, "~"):
+		_, err := regexp.Compile(origin[1:])
+		return err == nil
+	}
+	return strings.Contains(origin, "://")
+}
+
+// FieldError is one invalid config key
+type FieldError struct {
+	Origin  string // file, env or flag which the value comes from
+	Key     string // dotted config key, such as mysql.port
+	Message string
+}
+
+func (e FieldError) Error() string {
+	if e.Origin == "" {
+		return fmt.Sprintf("%s: %s", e.Key, e.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Origin, e.Key, e.Message)
+}
+
+// ValidationError aggregate every invalid key of a config, so all of them are fixed in one go
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Errors)+1)
+	lines = append(lines, fmt.Sprintf("config is invalid, %d error(s):", len(e.Errors)))
+	for _, fe := range e.Errors {
+		lines = append(lines, "  "+fe.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// structErrors check the validate tags of ptr, the keys are prefixed with prefix
+func structErrors(prefix string, ptr interface{}, origin func(key string) string) []FieldError {
+	err := getValidate().Struct(ptr)
+	if err == nil {
+		return nil
+	}
+	invalid, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return []FieldError{{Key: prefix, Message: err.Error()}}
+	}
+	errs := make([]FieldError, 0, len(invalid))
+	for _, fe := range invalid {
+		// the namespace starts with the struct type name, which is not part of the key
+		key := fe.Namespace()
+		if i := strings.Index(key, "."); i >= 0 {
+			key = key[i+1:]
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		errs = append(errs, newFieldError(key, validationMessage(fe), origin))
+	}
+	return errs
+}
+
+func newFieldError(key, message string, origin func(key string) string) FieldError {
+	fe := FieldError{Key: key, Message: message}
+	if origin != nil {
+		fe.Origin = origin(key)
+	}
+	return fe
+}
+
+// validationMessage turn a failed tag into a sentence naming the offending value
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		return fmt.Sprintf("%v is less than %s", fe.Value(), fe.Param())
+	case "max":
+		return fmt.Sprintf("%v is greater than %s", fe.Value(), fe.Param())
+	case "oneof":
+		return fmt.Sprintf("%q must be one of %s", fmt.Sprint(fe.Value()), strings.Join(strings.Fields(fe.Param()), ", "))
+	case "hostname_port":
+		return fmt.Sprintf("%q is not a host:port", fmt.Sprint(fe.Value()))
+	case "required_with":
+		return fmt.Sprintf("is required when %s is set", strings.Join(strings.Fields(fe.Param()), " or "))
+	case "excluded_with":
+		return fmt.Sprintf("must not be set with %s", strings.Join(strings.Fields(fe.Param()), " or "))
+	case "numeric":
+		return fmt.Sprintf("%q is not a number", fmt.Sprint(fe.Value()))
+	case "startswith":
+		return fmt.Sprintf("%q must start with %s", fmt.Sprint(fe.Value()), fe.Param())
+	case "cors_credentials":
+		return "must not be true with the * origin, browsers reject credentials for *, list the origins instead"
+	case "cors_origin":
+		return fmt.Sprintf("%q is not *, a scheme://host origin or a ~regexp", fmt.Sprint(fe.Value()))
+	}
+	return fmt.Sprintf("%v failed the %s check", fe.Value(), fe.Tag())
+}
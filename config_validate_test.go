@@ -0,0 +1,60 @@
+package platform
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigValidate(t *testing.T) {
+	c := Config{
+		System: System{Addr: 70000},
+		Log:    Log{Level: "verbose"},
+		Mysql:  Mysql{Dbname: "app", Port: "abc"},
+		Redis:  Redis{Addr: "127.0.0.1"},
+	}
+	err := c.validate(func(key string) string {
+		return "conf/testing/main.yaml"
+	})
+
+	var invalid *ValidationError
+	assert.True(t, errors.As(err, &invalid))
+	keys := make([]string, 0, len(invalid.Errors))
+	for _, fe := range invalid.Errors {
+		keys = append(keys, fe.Key)
+		assert.Equal(t, "conf/testing/main.yaml", fe.Origin)
+	}
+	assert.ElementsMatch(t, []string{"log.level", "system.addr", "mysql.path", "mysql.port", "redis.addr"}, keys)
+	assert.Contains(t, err.Error(), `conf/testing/main.yaml: log.level: "verbose" must be one of debug, info, warn, error`)
+	assert.Contains(t, err.Error(), "system.addr: 70000 is greater than 65535")
+
+	c = Config{System: System{Addr: 8080}}
+	assert.Nil(t, c.Validate())
+	// accepted before the validation: no system.addr, and any log format but json is console
+	assert.Nil(t, (&Config{Log: Log{Format: "text"}}).Validate())
+
+	// log.director is optional as before the validation, it defaults to log
+	v := viper.New()
+	v.Set("system.addr", 8080)
+	s, err := buildSnapshot(v, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "log", s.config.Log.Director)
+
+	c.Cors.Policies = []CorsPolicy{
+		{AllowOrigins: []string{"*", "https://*.example.com", `~^https://[a-z]+\.example\.net$`}},
+		{Prefix: "open", AllowOrigins: []string{"example.com", "~(["}},
+		{Prefix: "/empty"},
+		{Prefix: "/any", AllowOrigins: []string{"*"}, AllowCredentials: true},
+	}
+	err = c.Validate()
+	assert.True(t, errors.As(err, &invalid))
+	keys = keys[:0]
+	for _, fe := range invalid.Errors {
+		keys = append(keys, fe.Key)
+	}
+	assert.ElementsMatch(t, []string{"cors.policies[1].prefix", "cors.policies[1].allow-origins[0]", "cors.policies[1].allow-origins[1]", "cors.policies[2].allow-origins", "cors.policies[3].allow-credentials"}, keys)
+	assert.Contains(t, err.Error(), `"open" must start with /`)
+	assert.Contains(t, err.Error(), "cors.policies[3].allow-credentials: must not be true with the * origin")
+}
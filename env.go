@@ -2,15 +2,61 @@ package platform
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"sort"
+	"strings"
+	"sync"
 )
 
-//use export ENVIRONMENT=testing set global environment
+// use export ENVIRONMENT=testing set global environment
 const (
-	EnvTesting    = Environment("testing")
-	EnvProduction = Environment("production")
+	EnvDevelopment = Environment("development")
+	EnvTesting     = Environment("testing")
+	EnvStaging     = Environment("staging")
+	EnvProduction  = Environment("production")
+
+	// EnvironmentKey is the system environment variable selecting the Environment
+	EnvironmentKey = "ENVIRONMENT"
+)
+
+// EnvironmentDefaults is what an Environment implies when the flags and the config say nothing
+type EnvironmentDefaults struct {
+	GinMode     string // debug, release or test
+	LogLevel    string // used when log.level is empty
+	EnablePProf bool
+}
+
+var (
+	environmentsMu sync.RWMutex
+	environments   = map[Environment]EnvironmentDefaults{
+		EnvDevelopment: {GinMode: "debug", LogLevel: "debug", EnablePProf: true},
+		// an empty log.level has always meant debug in testing and production
+		EnvTesting:    {GinMode: "debug", LogLevel: "debug"},
+		EnvStaging:    {GinMode: "release", LogLevel: "info"},
+		EnvProduction: {GinMode: "release", LogLevel: "debug"},
+	}
 )
 
+// RegisterEnvironment add a custom environment, or replace the defaults of an existing one
+func RegisterEnvironment(env Environment, defaults EnvironmentDefaults) {
+	environmentsMu.Lock()
+	defer environmentsMu.Unlock()
+	environments[env] = defaults
+}
+
+// Environments list the registered environments
+func Environments() []Environment {
+	environmentsMu.RLock()
+	defer environmentsMu.RUnlock()
+	envs := make([]Environment, 0, len(environments))
+	for env := range environments {
+		envs = append(envs, env)
+	}
+	sort.Slice(envs, func(i, j int) bool { return envs[i] < envs[j] })
+	return envs
+}
+
 type Environment string
 
 func (env *Environment) String() string {
@@ -25,21 +71,62 @@ func (env *Environment) Testing() Environment {
 	return EnvTesting
 }
 
+func (env *Environment) Development() Environment {
+	return EnvDevelopment
+}
+
+func (env *Environment) Staging() Environment {
+	return EnvStaging
+}
+
 func (env Environment) Invalid() bool {
-	return env != EnvTesting && env != EnvProduction
+	environmentsMu.RLock()
+	defer environmentsMu.RUnlock()
+	_, ok := environments[env]
+	return !ok
+}
+
+// Defaults return the defaults registered for env, zero for an unknown one
+func (env Environment) Defaults() EnvironmentDefaults {
+	environmentsMu.RLock()
+	defer environmentsMu.RUnlock()
+	return environments[env]
 }
 
 // NewGlobalEnvironment 读取系统全局配置的环境变量
 func NewGlobalEnvironment() (Environment, error) {
-	environment, ok := os.LookupEnv("ENVIRONMENT")
+	environment, ok := os.LookupEnv(EnvironmentKey)
 	if !ok {
 		return "", errors.New("system environment:ENVIRONMENT not found")
 	}
 
 	env := Environment(environment)
-	if env != EnvTesting && env != EnvProduction {
-		return "", errors.New("environment not support, must be production, testing, development")
+	if env.Invalid() {
+		return "", unsupportedEnvironment(env)
 	}
 
 	return env, nil
 }
+
+// ResolveEnvironment pick the environment named by flag, else by ENVIRONMENT, else testing
+func ResolveEnvironment(flag string) (Environment, error) {
+	if flag != "" {
+		env := Environment(flag)
+		if env.Invalid() {
+			return "", unsupportedEnvironment(env)
+		}
+		return env, nil
+	}
+	if _, ok := os.LookupEnv(EnvironmentKey); ok {
+		return NewGlobalEnvironment()
+	}
+	return EnvTesting, nil
+}
+
+func unsupportedEnvironment(env Environment) error {
+	names := make([]string, 0)
+	for _, registered := range Environments() {
+		names = append(names, string(registered))
+	}
+	return fmt.Errorf("environment %q not support, must be %s", env, strings.Join(names, ", "))
+}
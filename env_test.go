@@ -0,0 +1,35 @@
+package platform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveEnvironment(t *testing.T) {
+	t.Setenv(EnvironmentKey, "staging")
+	env, err := ResolveEnvironment("")
+	assert.Nil(t, err)
+	assert.Equal(t, EnvStaging, env)
+	assert.Equal(t, "release", env.Defaults().GinMode)
+
+	env, err = ResolveEnvironment("development")
+	assert.Nil(t, err)
+	assert.True(t, env.Defaults().EnablePProf)
+	// an empty log.level keeps meaning debug in the environments which predate the defaults
+	for _, env := range []Environment{EnvTesting, EnvProduction} {
+		assert.Equal(t, "debug", env.Defaults().LogLevel)
+	}
+
+	_, err = ResolveEnvironment("canary")
+	assert.NotNil(t, err)
+	RegisterEnvironment("canary", EnvironmentDefaults{GinMode: "release", LogLevel: "warn"})
+	t.Cleanup(func() {
+		environmentsMu.Lock()
+		delete(environments, "canary")
+		environmentsMu.Unlock()
+	})
+	env, err = ResolveEnvironment("canary")
+	assert.Nil(t, err)
+	assert.Equal(t, "warn", env.Defaults().LogLevel)
+}
@@ -0,0 +1,47 @@
+package httpclient
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/chenxuan520/goweb-platform/utils"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// Transport propagate the request id found in the outgoing request context
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	requestID := utils.RequestIDFromContext(req.Context())
+	if requestID != "" && req.Header.Get(utils.HeaderRequestID) == "" {
+		// RoundTrip must not modify the caller's request
+		req = req.Clone(req.Context())
+		req.Header.Set(utils.HeaderRequestID, requestID)
+	}
+	return base.RoundTrip(req)
+}
+
+// New return a http client which forwards the request id, timeout <= 0 use 10s
+func New(timeout time.Duration) *http.Client {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &http.Client{
+		Transport: &Transport{},
+		Timeout:   timeout,
+	}
+}
+
+var _defaultClient = New(defaultTimeout)
+
+// GetClient return the shared platform http client
+func GetClient() *http.Client {
+	return _defaultClient
+}
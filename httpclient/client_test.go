@@ -0,0 +1,29 @@
+package httpclient_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chenxuan520/goweb-platform/httpclient"
+	"github.com/chenxuan520/goweb-platform/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDPropagation(t *testing.T) {
+	var got string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(utils.HeaderRequestID)
+	}))
+	defer ts.Close()
+
+	ctx := utils.WithRequestID(context.Background(), "req-1")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	assert.Nil(t, err)
+	resp, err := httpclient.GetClient().Do(req)
+	assert.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "req-1", got)
+	assert.Empty(t, req.Header.Get(utils.HeaderRequestID))
+}
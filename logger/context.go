@@ -0,0 +1,31 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/chenxuan520/goweb-platform/utils"
+	"go.uber.org/zap"
+)
+
+type loggerKey struct{}
+
+// WithContext return a copy of ctx carrying a child logger with the extra fields,
+// fields add up when called repeatedly along the request
+func WithContext(ctx context.Context, fields ...zap.Field) context.Context {
+	return context.WithValue(ctx, loggerKey{}, FromContext(ctx).With(fields...))
+}
+
+// FromContext return the request scoped logger of ctx, without one it falls back to
+// the global logger carrying the request id of ctx
+func FromContext(ctx context.Context) *zap.Logger {
+	if ctx == nil {
+		return _defaultLogger
+	}
+	if l, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok {
+		return l
+	}
+	if requestID := utils.RequestIDFromContext(ctx); requestID != "" {
+		return _defaultLogger.With(zap.String("request_id", requestID))
+	}
+	return _defaultLogger
+}
@@ -0,0 +1,32 @@
+package logger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/chenxuan520/goweb-platform/utils"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestFromContext(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	old := SetLogger(zap.New(core))
+	t.Cleanup(func() {
+		SetLogger(old)
+	})
+
+	FromContext(context.Background()).Info("plain")
+	ctx := utils.WithRequestID(context.Background(), "req-1")
+	FromContext(ctx).Info("with request id")
+	ctx = WithContext(ctx, zap.String("method", "GET"))
+	ctx = WithContext(ctx, zap.String("user_id", "42"))
+	FromContext(ctx).Info("scoped")
+
+	entries := logs.AllUntimed()
+	assert.Len(t, entries, 3)
+	assert.Empty(t, entries[0].ContextMap())
+	assert.Equal(t, map[string]interface{}{"request_id": "req-1"}, entries[1].ContextMap())
+	assert.Equal(t, map[string]interface{}{"request_id": "req-1", "method": "GET", "user_id": "42"}, entries[2].ContextMap())
+}
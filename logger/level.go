@@ -0,0 +1,80 @@
+package logger
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// _level gate every core built by Init, change it with SetLevel
+var _level = zap.NewAtomicLevelAt(zap.DebugLevel)
+
+var (
+	// afterFunc schedule the revert of SetLevelFor, replaced in tests
+	afterFunc = time.AfterFunc
+
+	revertMu    sync.Mutex
+	revertTimer *time.Timer
+	revertLevel zapcore.Level
+)
+
+// parseLevel keep the Init behaviour: anything unknown means debug
+func parseLevel(level string) zapcore.Level {
+	var lev zapcore.Level
+	if err := lev.UnmarshalText([]byte(level)); err != nil {
+		return zap.DebugLevel
+	}
+	return lev
+}
+
+// AtomicLevel return the level shared by all the cores of the global logger
+func AtomicLevel() zap.AtomicLevel {
+	return _level
+}
+
+// GetLevel return the current level name
+func GetLevel() string {
+	return _level.Level().String()
+}
+
+// SetLevel change the level at runtime and cancel a pending revert of SetLevelFor
+func SetLevel(level string) error {
+	return SetLevelFor(level, 0)
+}
+
+// SetLevelFor change the level and, when duration > 0, go back to the level in force
+// before the first temporary change once it expires
+func SetLevelFor(level string, duration time.Duration) error {
+	var lev zapcore.Level
+	if err := lev.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("unknown log level %q", level)
+	}
+
+	revertMu.Lock()
+	defer revertMu.Unlock()
+	if revertTimer != nil {
+		revertTimer.Stop()
+		revertTimer = nil
+	} else {
+		revertLevel = _level.Level()
+	}
+	if duration > 0 {
+		var timer *time.Timer
+		timer = afterFunc(duration, func() {
+			revertMu.Lock()
+			defer revertMu.Unlock()
+			// replaced by a later call while waiting for the lock
+			if revertTimer != timer {
+				return
+			}
+			revertTimer = nil
+			_level.SetLevel(revertLevel)
+		})
+		revertTimer = timer
+	}
+	_level.SetLevel(lev)
+	return nil
+}
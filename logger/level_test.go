@@ -0,0 +1,38 @@
+package logger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetLevelFor(t *testing.T) {
+	level := _level.Level()
+	var reverts []func()
+	afterFunc = func(d time.Duration, f func()) *time.Timer {
+		reverts = append(reverts, f)
+		// a timer never firing, the test runs the revert itself
+		return time.AfterFunc(time.Hour, func() {})
+	}
+	t.Cleanup(func() {
+		afterFunc = time.AfterFunc
+		_ = SetLevel(level.String())
+	})
+
+	assert.Nil(t, SetLevel("warn"))
+	assert.NotNil(t, SetLevel("verbose"))
+	assert.Equal(t, "warn", GetLevel())
+
+	assert.Nil(t, SetLevelFor("debug", time.Minute))
+	assert.Nil(t, SetLevelFor("info", time.Minute))
+	assert.Equal(t, "info", GetLevel())
+	if assert.Len(t, reverts, 2) {
+		// the replaced revert does nothing
+		reverts[0]()
+		assert.Equal(t, "info", GetLevel())
+		reverts[1]()
+	}
+	// back to the level before the first temporary change
+	assert.Equal(t, "warn", GetLevel())
+}
@@ -19,34 +19,25 @@ func Init(level string, format, prefix, director string, showLine bool, encodeLe
 		fmt.Printf("create %v directory\n", director)
 		_ = os.Mkdir(fmt.Sprintf("%s", director), os.ModePerm)
 	}
+	_level.SetLevel(parseLevel(level))
+	// every file core is built and gated by the atomic level, so the level can change at runtime
 	debugPriority := zap.LevelEnablerFunc(func(lev zapcore.Level) bool {
-		return lev == zap.DebugLevel
+		return lev == zap.DebugLevel && _level.Enabled(lev)
 	})
 	infoPriority := zap.LevelEnablerFunc(func(lev zapcore.Level) bool {
-		return lev == zap.InfoLevel
+		return lev == zap.InfoLevel && _level.Enabled(lev)
 	})
 	warnPriority := zap.LevelEnablerFunc(func(lev zapcore.Level) bool {
-		return lev == zap.WarnLevel
+		return lev == zap.WarnLevel && _level.Enabled(lev)
 	})
 	errorPriority := zap.LevelEnablerFunc(func(lev zapcore.Level) bool {
-		return lev >= zap.ErrorLevel
+		return lev >= zap.ErrorLevel && _level.Enabled(lev)
 	})
-	cores := make([]zapcore.Core, 0)
-	switch level {
-	case "info":
-		cores = append(cores, getEncoderCore(logInConsole, prefix, format, encodeLevel, stacktraceKey, fmt.Sprintf("%s/server_info.log", director), infoPriority))
-		cores = append(cores, getEncoderCore(logInConsole, prefix, format, encodeLevel, stacktraceKey, fmt.Sprintf("%s/server_warn.log", director), warnPriority))
-		cores = append(cores, getEncoderCore(logInConsole, prefix, format, encodeLevel, stacktraceKey, fmt.Sprintf("%s/server_error.log", director), errorPriority))
-	case "warn":
-		cores = append(cores, getEncoderCore(logInConsole, prefix, format, encodeLevel, stacktraceKey, fmt.Sprintf("%s/server_warn.log", director), warnPriority))
-		cores = append(cores, getEncoderCore(logInConsole, prefix, format, encodeLevel, stacktraceKey, fmt.Sprintf("%s/server_error.log", director), errorPriority))
-	case "error":
-		cores = append(cores, getEncoderCore(logInConsole, prefix, format, encodeLevel, stacktraceKey, fmt.Sprintf("%s/server_error.log", director), errorPriority))
-	default:
-		cores = append(cores, getEncoderCore(logInConsole, prefix, format, encodeLevel, stacktraceKey, fmt.Sprintf("%s/server_debug.log", director), debugPriority))
-		cores = append(cores, getEncoderCore(logInConsole, prefix, format, encodeLevel, stacktraceKey, fmt.Sprintf("%s/server_info.log", director), infoPriority))
-		cores = append(cores, getEncoderCore(logInConsole, prefix, format, encodeLevel, stacktraceKey, fmt.Sprintf("%s/server_warn.log", director), warnPriority))
-		cores = append(cores, getEncoderCore(logInConsole, prefix, format, encodeLevel, stacktraceKey, fmt.Sprintf("%s/server_error.log", director), errorPriority))
+	cores := []zapcore.Core{
+		getEncoderCore(logInConsole, prefix, format, encodeLevel, stacktraceKey, fmt.Sprintf("%s/server_debug.log", director), debugPriority),
+		getEncoderCore(logInConsole, prefix, format, encodeLevel, stacktraceKey, fmt.Sprintf("%s/server_info.log", director), infoPriority),
+		getEncoderCore(logInConsole, prefix, format, encodeLevel, stacktraceKey, fmt.Sprintf("%s/server_warn.log", director), warnPriority),
+		getEncoderCore(logInConsole, prefix, format, encodeLevel, stacktraceKey, fmt.Sprintf("%s/server_error.log", director), errorPriority),
 	}
 	logger = zap.New(zapcore.NewTee(cores[:]...), zap.AddCaller())
 
@@ -126,3 +117,30 @@ func Shutdown() {
 func GetLogger() *zap.Logger {
 	return _defaultLogger
 }
+
+// SetLogger replace the global logger, e.g. by an observer in tests, and return the previous one
+func SetLogger(logger *zap.Logger) *zap.Logger {
+	old := _defaultLogger
+	_defaultLogger = logger
+	return old
+}
+
+// NewAccessLogger build a logger writing the access log to its own rotated file,
+// the json format keeps the time and fields while the other formats write the message as is
+func NewAccessLogger(fileName, format string, logInConsole bool) *zap.Logger {
+	config := zapcore.EncoderConfig{
+		MessageKey: "message",
+		LineEnding: zapcore.DefaultLineEnding,
+	}
+	var encoder zapcore.Encoder
+	if format == "json" {
+		config.TimeKey = "time"
+		config.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+			enc.AppendString(t.Format(utils.TimeFormatDateV4))
+		}
+		encoder = zapcore.NewJSONEncoder(config)
+	} else {
+		encoder = zapcore.NewConsoleEncoder(config)
+	}
+	return zap.New(zapcore.NewCore(encoder, getWriteSyncer(logInConsole, fileName), zap.InfoLevel))
+}
@@ -0,0 +1,196 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/chenxuan520/goweb-platform/utils"
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"gorm.io/gorm"
+)
+
+// systemRefreshInterval is how often the host stats are read, reading the cpu usage
+// blocks for a while so a scrape only reads the last snapshot
+const systemRefreshInterval = 15 * time.Second
+
+// systemCollector export the host stats of utils.GetServerInfo, refreshed in the
+// background from the first scrape on
+type systemCollector struct {
+	read     func() (*utils.Server, error)
+	interval time.Duration
+	once     sync.Once
+	mu       sync.RWMutex
+	server   *utils.Server
+	err      error
+
+	cpuPercent      *prometheus.Desc
+	cpuCores        *prometheus.Desc
+	memUsedBytes    *prometheus.Desc
+	memTotalBytes   *prometheus.Desc
+	diskUsedBytes   *prometheus.Desc
+	diskTotalBytes  *prometheus.Desc
+	scrapeErrorDesc *prometheus.Desc
+}
+
+func newSystemCollector(read func() (*utils.Server, error), interval time.Duration) *systemCollector {
+	return &systemCollector{
+		read:            read,
+		interval:        interval,
+		cpuPercent:      prometheus.NewDesc(namespace+"_system_cpu_usage_percent", "CPU usage percent per logical cpu.", []string{"cpu"}, nil),
+		cpuCores:        prometheus.NewDesc(namespace+"_system_cpu_cores", "Number of physical cpu cores.", nil, nil),
+		memUsedBytes:    prometheus.NewDesc(namespace+"_system_memory_used_bytes", "Used memory of the host.", nil, nil),
+		memTotalBytes:   prometheus.NewDesc(namespace+"_system_memory_total_bytes", "Total memory of the host.", nil, nil),
+		diskUsedBytes:   prometheus.NewDesc(namespace+"_system_disk_used_bytes", "Used space of the root filesystem.", nil, nil),
+		diskTotalBytes:  prometheus.NewDesc(namespace+"_system_disk_total_bytes", "Total space of the root filesystem.", nil, nil),
+		scrapeErrorDesc: prometheus.NewDesc(namespace+"_system_scrape_error", "1 if reading the host stats failed.", nil, nil),
+	}
+}
+
+func (s *systemCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.cpuPercent
+	ch <- s.cpuCores
+	ch <- s.memUsedBytes
+	ch <- s.memTotalBytes
+	ch <- s.diskUsedBytes
+	ch <- s.diskTotalBytes
+	ch <- s.scrapeErrorDesc
+}
+
+// refresh read the host stats and store them as the current snapshot
+func (s *systemCollector) refresh() {
+	server, err := s.read()
+	s.mu.Lock()
+	s.server, s.err = server, err
+	s.mu.Unlock()
+}
+
+// start read a first snapshot then keep it fresh on a ticker
+func (s *systemCollector) start() {
+	s.refresh()
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.refresh()
+		}
+	}()
+}
+
+func (s *systemCollector) Collect(ch chan<- prometheus.Metric) {
+	s.once.Do(s.start)
+	s.mu.RLock()
+	server, err := s.server, s.err
+	s.mu.RUnlock()
+	scrapeError := 0.0
+	if err != nil {
+		scrapeError = 1
+	}
+	ch <- prometheus.MustNewConstMetric(s.scrapeErrorDesc, prometheus.GaugeValue, scrapeError)
+	if server == nil {
+		return
+	}
+	for i, percent := range server.Cpu.Cpus {
+		ch <- prometheus.MustNewConstMetric(s.cpuPercent, prometheus.GaugeValue, percent, utils.IntToString(i))
+	}
+	if server.Cpu.Cores > 0 {
+		ch <- prometheus.MustNewConstMetric(s.cpuCores, prometheus.GaugeValue, float64(server.Cpu.Cores))
+	}
+	if server.Rrm.TotalMB > 0 {
+		ch <- prometheus.MustNewConstMetric(s.memUsedBytes, prometheus.GaugeValue, float64(server.Rrm.UsedMB)*utils.MB)
+		ch <- prometheus.MustNewConstMetric(s.memTotalBytes, prometheus.GaugeValue, float64(server.Rrm.TotalMB)*utils.MB)
+	}
+	if server.Disk.TotalMB > 0 {
+		ch <- prometheus.MustNewConstMetric(s.diskUsedBytes, prometheus.GaugeValue, float64(server.Disk.UsedMB)*utils.MB)
+		ch <- prometheus.MustNewConstMetric(s.diskTotalBytes, prometheus.GaugeValue, float64(server.Disk.TotalMB)*utils.MB)
+	}
+}
+
+// redisPoolCollector export the connection pool stats of a go-redis client
+type redisPoolCollector struct {
+	client     *redis.Client
+	hits       *prometheus.Desc
+	misses     *prometheus.Desc
+	timeouts   *prometheus.Desc
+	totalConns *prometheus.Desc
+	idleConns  *prometheus.Desc
+	staleConns *prometheus.Desc
+}
+
+func newRedisPoolCollector(name string, client *redis.Client) *redisPoolCollector {
+	labels := prometheus.Labels{"client": name}
+	desc := func(metric, help string) *prometheus.Desc {
+		return prometheus.NewDesc(namespace+"_redis_pool_"+metric, help, nil, labels)
+	}
+	return &redisPoolCollector{
+		client:     client,
+		hits:       desc("hits_total", "Number of times a free connection was found in the pool."),
+		misses:     desc("misses_total", "Number of times a free connection was NOT found in the pool."),
+		timeouts:   desc("timeouts_total", "Number of times a wait timeout occurred."),
+		totalConns: desc("connections", "Number of total connections in the pool."),
+		idleConns:  desc("idle_connections", "Number of idle connections in the pool."),
+		staleConns: desc("stale_connections_total", "Number of stale connections removed from the pool."),
+	}
+}
+
+func (r *redisPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- r.hits
+	ch <- r.misses
+	ch <- r.timeouts
+	ch <- r.totalConns
+	ch <- r.idleConns
+	ch <- r.staleConns
+}
+
+func (r *redisPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := r.client.PoolStats()
+	ch <- prometheus.MustNewConstMetric(r.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(r.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(r.timeouts, prometheus.CounterValue, float64(stats.Timeouts))
+	ch <- prometheus.MustNewConstMetric(r.totalConns, prometheus.GaugeValue, float64(stats.TotalConns))
+	ch <- prometheus.MustNewConstMetric(r.idleConns, prometheus.GaugeValue, float64(stats.IdleConns))
+	ch <- prometheus.MustNewConstMetric(r.staleConns, prometheus.CounterValue, float64(stats.StaleConns))
+}
+
+var (
+	poolCollectorsMu sync.Mutex
+	poolCollectors   = make(map[string]prometheus.Collector)
+)
+
+// registerPool register c under key, replacing the collector of a reloaded pool
+func registerPool(key string, c prometheus.Collector) error {
+	poolCollectorsMu.Lock()
+	defer poolCollectorsMu.Unlock()
+	if old, ok := poolCollectors[key]; ok {
+		Registry.Unregister(old)
+		delete(poolCollectors, key)
+	}
+	if err := Registry.Register(c); err != nil {
+		return err
+	}
+	poolCollectors[key] = c
+	return nil
+}
+
+// RegisterGormDB export the sql.DBStats of a gorm connection pool under the given name,
+// registering the same name again replaces the previous pool
+func RegisterGormDB(name string, db *gorm.DB) error {
+	if db == nil {
+		return nil
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return registerPool("gorm:"+name, collectors.NewDBStatsCollector(sqlDB, name))
+}
+
+// RegisterRedis export the pool stats of a go-redis client under the given name,
+// registering the same name again replaces the previous client
+func RegisterRedis(name string, client *redis.Client) error {
+	if client == nil {
+		return nil
+	}
+	return registerPool("redis:"+name, newRedisPoolCollector(name, client))
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/chenxuan520/goweb-platform/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestGinMiddleware(t *testing.T) {
+	t.Cleanup(func() {
+		httpRequestsTotal.Reset()
+		httpRequestDuration.Reset()
+		httpRequestsInFlight.Reset()
+	})
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(GinMiddleware())
+	var inFlight float64
+	engine.GET("/users/:id", func(c *gin.Context) {
+		inFlight = testutil.ToFloat64(httpRequestsInFlight.WithLabelValues(http.MethodGet, "/users/:id"))
+		c.Status(http.StatusOK)
+	})
+
+	for _, path := range []string{"/users/1", "/users/2", "/nothing"} {
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// the route template is the label, not the path
+	assert.Equal(t, 2.0, testutil.ToFloat64(httpRequestsTotal.WithLabelValues(http.MethodGet, "/users/:id", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(httpRequestsTotal.WithLabelValues(http.MethodGet, unmatchedRoute, "404")))
+	assert.Equal(t, 2, testutil.CollectAndCount(httpRequestsTotal))
+	assert.Equal(t, 2, testutil.CollectAndCount(httpRequestDuration))
+	assert.Equal(t, 1.0, inFlight)
+	assert.Equal(t, 0.0, testutil.ToFloat64(httpRequestsInFlight.WithLabelValues(http.MethodGet, "/users/:id")))
+}
+
+func TestSystemCollector(t *testing.T) {
+	var reads int32
+	s := newSystemCollector(func() (*utils.Server, error) {
+		atomic.AddInt32(&reads, 1)
+		return &utils.Server{Cpu: utils.Cpu{Cpus: []float64{10, 20}, Cores: 2}}, errors.New("no disk")
+	}, time.Hour)
+
+	// cpu percent per cpu, cores and the scrape error
+	assert.Equal(t, 4, testutil.CollectAndCount(s))
+	assert.Equal(t, 4, testutil.CollectAndCount(s))
+	// the scrapes read the snapshot, the host stats are read once until the next tick
+	assert.Equal(t, int32(1), atomic.LoadInt32(&reads))
+	assert.Equal(t, 1, testutil.CollectAndCount(s, namespace+"_system_scrape_error"))
+}
+
+func TestRegisterPools(t *testing.T) {
+	t.Cleanup(func() {
+		poolCollectorsMu.Lock()
+		defer poolCollectorsMu.Unlock()
+		for key, c := range poolCollectors {
+			Registry.Unregister(c)
+			delete(poolCollectors, key)
+		}
+	})
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	assert.Nil(t, client.Ping(client.Context()).Err())
+	assert.Nil(t, RegisterRedis("cache", client))
+	// registering the name again replace the previous client instead of failing
+	assert.Nil(t, RegisterRedis("cache", client))
+	assert.Nil(t, RegisterRedis("cache", nil))
+	count, err := testutil.GatherAndCount(Registry, namespace+"_redis_pool_connections", namespace+"_redis_pool_hits_total")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, count)
+	assert.Nil(t, testutil.GatherAndCompare(Registry, strings.NewReader(`
+# HELP goweb_redis_pool_connections Number of total connections in the pool.
+# TYPE goweb_redis_pool_connections gauge
+goweb_redis_pool_connections{client="cache"} 1
+`), namespace+"_redis_pool_connections"))
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	assert.Nil(t, err)
+	assert.Nil(t, RegisterGormDB("main", db))
+	assert.Nil(t, RegisterGormDB("main", db))
+	count, err = testutil.GatherAndCount(Registry, "go_sql_max_open_connections")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+}
@@ -0,0 +1,31 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute label requests that hit no route, so scanners can not blow up the cardinality
+const unmatchedRoute = "unmatched"
+
+// GinMiddleware record count, latency and in-flight requests per route template
+func GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := c.Request.Method
+		inFlight := httpRequestsInFlight.WithLabelValues(method, route)
+		inFlight.Inc()
+		start := time.Now()
+		defer func() {
+			inFlight.Dec()
+			httpRequestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+			httpRequestsTotal.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		}()
+		c.Next()
+	}
+}
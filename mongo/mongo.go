@@ -1,11 +1,16 @@
 package mongo
 
 import (
+	"sync"
+
 	"gitlab.dian.org.cn/helper/miniapp-platform/logger"
 	mgo "gopkg.in/mgo.v2"
 )
 
-var _defaultDB *mgo.Session
+var (
+	_defaultDB *mgo.Session
+	mu         sync.RWMutex
+)
 
 func Init(host, port, dbname, user, password string) error {
 	mgosession, err := mgo.Dial(host + ":" + port)
@@ -25,10 +30,26 @@ func Init(host, port, dbname, user, password string) error {
 			return err
 		}
 	}
+	mu.Lock()
 	_defaultDB = mgosession
+	mu.Unlock()
 	return nil
 }
 
 func GetMongoDB() *mgo.Session {
+	mu.RLock()
+	defer mu.RUnlock()
 	return _defaultDB
 }
+
+// Close close the default session and the sockets of its pool
+func Close() error {
+	mu.Lock()
+	session := _defaultDB
+	_defaultDB = nil
+	mu.Unlock()
+	if session != nil {
+		session.Close()
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloseWithoutSession(t *testing.T) {
+	// closing before or after a failed Init is a no-op
+	assert.Nil(t, Close())
+	assert.Nil(t, GetMongoDB())
+}
@@ -7,23 +7,67 @@ import (
 	"gitlab.dian.org.cn/helper/miniapp-platform/logger"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"sync"
 )
 
-var _defaultDB *gorm.DB
+var (
+	_defaultDB *gorm.DB
+	mu         sync.RWMutex
+)
 
 func Init(dsn string) (*gorm.DB, error) {
+	db, err := open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	_defaultDB = db
+	return db, nil
+}
 
+func open(dsn string) (*gorm.DB, error) {
 	mysqlConfig := mysql.Config{
 		DSN:                       dsn,
 		DefaultStringSize:         256,
 		SkipInitializeWithVersion: false,
 	}
-	if db, err := gorm.Open(mysql.New(mysqlConfig), &gorm.Config{}); err != nil {
+	return gorm.Open(mysql.New(mysqlConfig), &gorm.Config{})
+}
+
+// Reload connect with the new dsn and swap the default DB, the old pool is closed
+// once its running queries finish. On error the old DB stays in use
+func Reload(dsn string) (*gorm.DB, error) {
+	db, err := open(dsn)
+	if err != nil {
 		return nil, err
-	} else {
-		_defaultDB = db
-		return db, nil
 	}
+	mu.Lock()
+	old := _defaultDB
+	_defaultDB = db
+	mu.Unlock()
+	if old != nil {
+		if sqlDB, err := old.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	}
+	return db, nil
+}
+
+// Close close the default DB once its running queries finish
+func Close() error {
+	mu.Lock()
+	db := _defaultDB
+	_defaultDB = nil
+	mu.Unlock()
+	if db == nil {
+		return nil
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
 }
 
 func CreateMysqlDsn(username, password, path, port, dbname, config string) string {
@@ -31,6 +75,8 @@ func CreateMysqlDsn(username, password, path, port, dbname, config string) strin
 }
 
 func GetMysqlDB() *gorm.DB {
+	mu.RLock()
+	defer mu.RUnlock()
 	if _defaultDB == nil {
 		logger.GetLogger().Error("mysql database is not initialized")
 		return nil
@@ -38,6 +84,15 @@ func GetMysqlDB() *gorm.DB {
 	return _defaultDB
 }
 
+// SetMysqlDB replace the default DB, e.g. by a sqlite one in tests, and return the previous one
+func SetMysqlDB(db *gorm.DB) *gorm.DB {
+	mu.Lock()
+	defer mu.Unlock()
+	old := _defaultDB
+	_defaultDB = db
+	return old
+}
+
 //to create database
 func CreateDatabase(dsn string, driver string, createSql string) error {
 	db, err := sql.Open(driver, dsn)
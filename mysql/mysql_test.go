@@ -0,0 +1,27 @@
+package mysql
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestClose(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	assert.Nil(t, err)
+	old := SetMysqlDB(db)
+	t.Cleanup(func() {
+		SetMysqlDB(old)
+	})
+	sqlDB, err := db.DB()
+	assert.Nil(t, err)
+	assert.Nil(t, sqlDB.Ping())
+
+	assert.Nil(t, Close())
+	assert.NotNil(t, sqlDB.Ping())
+	// nothing left to close
+	assert.Nil(t, Close())
+}
@@ -2,13 +2,17 @@ package redis
 
 import (
 	"context"
+	"sync"
 
 	"github.com/chenxuan520/goweb-platform/logger"
 	"github.com/go-redis/redis/v8"
 )
 
 //redis连接
-var _defaultRedis *redis.Client
+var (
+	_defaultRedis *redis.Client
+	mu            sync.RWMutex
+)
 
 func Init(addr, password string, db int) (r *redis.Client, err error) {
 	client := redis.NewClient(&redis.Options{
@@ -17,15 +21,62 @@ func Init(addr, password string, db int) (r *redis.Client, err error) {
 		DB:       db,       // use default DB
 	})
 	_, err = client.Ping(context.Background()).Result()
+	mu.Lock()
+	_defaultRedis = client
+	mu.Unlock()
+
+	return client, err
+}
+
+// Reload connect with the new options and swap the default client, the old one is closed.
+// A new client failing to ping is discarded and the old one stays in use
+func Reload(addr, password string, db int) (*redis.Client, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	mu.Lock()
+	old := _defaultRedis
 	_defaultRedis = client
+	mu.Unlock()
+	if old != nil {
+		_ = old.Close()
+	}
+	return client, nil
+}
 
-	return
+// Close close the default client and its pool
+func Close() error {
+	mu.Lock()
+	client := _defaultRedis
+	_defaultRedis = nil
+	mu.Unlock()
+	if client == nil {
+		return nil
+	}
+	return client.Close()
 }
 
 func GetRedis() *redis.Client {
+	mu.RLock()
+	defer mu.RUnlock()
 	if _defaultRedis == nil {
 		logger.GetLogger().Error("redis is not initialized")
 		return nil
 	}
 	return _defaultRedis
 }
+
+// SetRedis replace the default client, e.g. by one of an in-memory server in tests, and return the previous one
+func SetRedis(client *redis.Client) *redis.Client {
+	mu.Lock()
+	defer mu.Unlock()
+	old := _defaultRedis
+	_defaultRedis = client
+	return old
+}
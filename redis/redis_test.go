@@ -0,0 +1,24 @@
+package redis
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClose(t *testing.T) {
+	mr := miniredis.RunT(t)
+	old := SetRedis(nil)
+	client, err := Init(mr.Addr(), "", 0)
+	assert.Nil(t, err)
+	t.Cleanup(func() {
+		SetRedis(old)
+	})
+
+	assert.Nil(t, Close())
+	assert.NotNil(t, client.Ping(context.Background()).Err())
+	// nothing left to close
+	assert.Nil(t, Close())
+}
@@ -0,0 +1,107 @@
+package response
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultLanguage is the language of the messages when the request asks for none we know
+var DefaultLanguage = "en"
+
+// CodeOK is the code of a successful response
+const CodeOK = 0
+
+// Messages map a language, such as en or zh, to the message of a code
+type Messages map[string]string
+
+// CodeInfo is a registered business code
+type CodeInfo struct {
+	Code     int
+	Status   int
+	Messages Messages
+}
+
+var (
+	codesMu sync.RWMutex
+	codes   = make(map[int]CodeInfo)
+)
+
+// the generic codes, 5 digits starting with the http status. Register the business
+// ones out of this range, e.g. 10001 and up
+var (
+	ErrBadRequest      = Register(40000, http.StatusBadRequest, Messages{"en": "bad request", "zh": "请求参数错误"})
+	ErrUnauthorized    = Register(40100, http.StatusUnauthorized, Messages{"en": "unauthorized", "zh": "未登录或登录已过期"})
+	ErrForbidden       = Register(40300, http.StatusForbidden, Messages{"en": "forbidden", "zh": "没有权限"})
+	ErrNotFound        = Register(40400, http.StatusNotFound, Messages{"en": "not found", "zh": "资源不存在"})
+	ErrConflict        = Register(40900, http.StatusConflict, Messages{"en": "conflict", "zh": "资源冲突"})
+	ErrPayloadTooLarge = Register(41300, http.StatusRequestEntityTooLarge, Messages{"en": "request body too large", "zh": "请求体过大"})
+	ErrTooManyRequests = Register(42900, http.StatusTooManyRequests, Messages{"en": "too many requests", "zh": "请求过于频繁"})
+	ErrInternal        = Register(50000, http.StatusInternalServerError, Messages{"en": "internal server error", "zh": "服务器内部错误"})
+	ErrUnavailable     = Register(50300, http.StatusServiceUnavailable, Messages{"en": "service unavailable", "zh": "服务暂不可用"})
+)
+
+func init() {
+	mustRegister(CodeInfo{Code: CodeOK, Status: http.StatusOK, Messages: Messages{"en": "ok", "zh": "成功"}})
+}
+
+// Register add a business code answered with the http status and return its error,
+// keep it in a package variable. A code registered twice panics, like a duplicated route
+func Register(code, status int, messages Messages) *Error {
+	mustRegister(CodeInfo{Code: code, Status: status, Messages: messages})
+	return &Error{Code: code, Status: status}
+}
+
+func mustRegister(info CodeInfo) {
+	codesMu.Lock()
+	defer codesMu.Unlock()
+	if _, ok := codes[info.Code]; ok {
+		panic(fmt.Sprintf("response:code %d registered twice", info.Code))
+	}
+	codes[info.Code] = info
+}
+
+// Lookup return the registered code
+func Lookup(code int) (CodeInfo, bool) {
+	codesMu.RLock()
+	defer codesMu.RUnlock()
+	info, ok := codes[code]
+	return info, ok
+}
+
+// Codes list the registered codes sorted, e.g. to document them
+func Codes() []CodeInfo {
+	codesMu.RLock()
+	defer codesMu.RUnlock()
+	list := make([]CodeInfo, 0, len(codes))
+	for _, info := range codes {
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Code < list[j].Code
+	})
+	return list
+}
+
+// Message return the message of code in the first language of acceptLanguage it has,
+// then in DefaultLanguage. acceptLanguage is an Accept-Language header, such as zh-CN,zh;q=0.9
+func Message(code int, acceptLanguage string) string {
+	info, ok := Lookup(code)
+	if !ok {
+		return ""
+	}
+	for _, lang := range strings.Split(acceptLanguage, ",") {
+		lang = strings.ToLower(strings.TrimSpace(strings.SplitN(lang, ";", 2)[0]))
+		if msg, ok := info.Messages[lang]; ok {
+			return msg
+		}
+		if i := strings.IndexByte(lang, '-'); i > 0 {
+			if msg, ok := info.Messages[lang[:i]]; ok {
+				return msg
+			}
+		}
+	}
+	return info.Messages[DefaultLanguage]
+}
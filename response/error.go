@@ -0,0 +1,69 @@
+package response
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Error is a business error. Handlers answer it with Fail, or pass it to c.Error and
+// let Middleware render it
+type Error struct {
+	Code   int
+	Status int
+	// Message replace the registered messages of Code when set
+	Message string
+	// Data is sent along, such as the invalid fields
+	Data interface{}
+	// cause is logged, never sent
+	cause error
+}
+
+func (e *Error) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = Message(e.Code, DefaultLanguage)
+	}
+	if e.cause != nil {
+		return fmt.Sprintf("code %d:%s:%s", e.Code, msg, e.cause.Error())
+	}
+	return fmt.Sprintf("code %d:%s", e.Code, msg)
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is match the errors of the same code, so errors.Is(err, ErrNotFound) holds for a wrapped copy
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Wrap return a copy of e caused by err
+func (e *Error) Wrap(err error) *Error {
+	c := *e
+	c.cause = err
+	return &c
+}
+
+// WithMessage return a copy of e with a message of its own instead of the registered ones
+func (e *Error) WithMessage(format string, args ...interface{}) *Error {
+	c := *e
+	c.Message = fmt.Sprintf(format, args...)
+	return &c
+}
+
+// WithData return a copy of e sending data along
+func (e *Error) WithData(data interface{}) *Error {
+	c := *e
+	c.Data = data
+	return &c
+}
+
+// status is the http status of e, 500 when unset
+func (e *Error) status() int {
+	if e.Status == 0 {
+		return http.StatusInternalServerError
+	}
+	return e.Status
+}
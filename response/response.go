@@ -0,0 +1,99 @@
+// Package response write every api response in the same envelope:
+//
+//	{"code": 0, "message": "ok", "data": {...}, "request_id": "..."}
+//
+// code is 0 on success, otherwise a business code registered with Register
+package response
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/chenxuan520/goweb-platform/logger"
+	"github.com/chenxuan520/goweb-platform/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Body is the response envelope
+type Body struct {
+	Code      int         `json:"code"`
+	Message   string      `json:"message"`
+	Data      interface{} `json:"data,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+}
+
+// PageData is the data of Page
+type PageData struct {
+	Items interface{} `json:"items"`
+	Total int64       `json:"total"`
+	Page  int         `json:"page"`
+	Size  int         `json:"size"`
+}
+
+// OK answer 200 with data
+func OK(c *gin.Context, data interface{}) {
+	c.JSON(http.StatusOK, Body{
+		Code:      CodeOK,
+		Message:   Message(CodeOK, c.GetHeader("Accept-Language")),
+		Data:      data,
+		RequestID: utils.RequestIDFromContext(c.Request.Context()),
+	})
+}
+
+// Page answer 200 with one page of items out of total
+func Page(c *gin.Context, items interface{}, total int64, page, size int) {
+	OK(c, PageData{Items: items, Total: total, Page: page, Size: size})
+}
+
+// Fail answer err: an *Error with its code and status, anything else as ErrInternal.
+// A 5xx error with a cause is logged, the client only gets the message
+func Fail(c *gin.Context, err error) {
+	e := asError(err)
+	msg := e.Message
+	if msg == "" {
+		msg = Message(e.Code, c.GetHeader("Accept-Language"))
+	}
+	logCause(c, e, err, "failed")
+	c.AbortWithStatusJSON(e.status(), Body{
+		Code:      e.Code,
+		Message:   msg,
+		Data:      e.Data,
+		RequestID: utils.RequestIDFromContext(c.Request.Context()),
+	})
+}
+
+// asError turn err into an *Error, anything else is ErrInternal
+func asError(err error) *Error {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = ErrInternal.Wrap(err)
+	}
+	return e
+}
+
+// logCause log err when it is a 5xx error with a cause, the client only gets the message
+func logCause(c *gin.Context, e *Error, err error, what string) {
+	if e.status() < http.StatusInternalServerError || e.cause == nil {
+		return
+	}
+	logger.FromContext(c.Request.Context()).Error(fmt.Sprintf("response:%s %s %s , error:%s", c.Request.Method, c.Request.URL.Path, what, err.Error()))
+}
+
+// Middleware render the last error passed to c.Error with Fail. When the handler
+// already wrote a response the errors are dropped, the 5xx ones with a cause are logged
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if len(c.Errors) == 0 {
+			return
+		}
+		if c.Writer.Written() {
+			for _, err := range c.Errors {
+				logCause(c, asError(err.Err), err.Err, "failed after the response was written")
+			}
+			return
+		}
+		Fail(c, c.Errors.Last().Err)
+	}
+}
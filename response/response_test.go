@@ -0,0 +1,98 @@
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chenxuan520/goweb-platform/logger"
+	"github.com/chenxuan520/goweb-platform/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var errQuota = Register(10001, http.StatusForbidden, Messages{"en": "quota exceeded", "zh": "配额已用完"})
+
+func serve(t *testing.T, handler gin.HandlerFunc, acceptLanguage string) (int, Body) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(utils.WithRequestID(c.Request.Context(), "req-1"))
+	}, Middleware())
+	engine.GET("/", handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Language", acceptLanguage)
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	var body Body
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return w.Code, body
+}
+
+func TestResponse(t *testing.T) {
+	core, logs := observer.New(zap.ErrorLevel)
+	old := logger.SetLogger(zap.New(core))
+	t.Cleanup(func() {
+		logger.SetLogger(old)
+	})
+
+	code, body := serve(t, func(c *gin.Context) { OK(c, gin.H{"id": 1}) }, "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, Body{Code: CodeOK, Message: "ok", Data: map[string]interface{}{"id": float64(1)}, RequestID: "req-1"}, body)
+
+	code, body = serve(t, func(c *gin.Context) { Page(c, []int{1, 2}, 12, 2, 2) }, "zh-CN,zh;q=0.9")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "成功", body.Message)
+	assert.Equal(t, map[string]interface{}{"items": []interface{}{float64(1), float64(2)}, "total": float64(12), "page": float64(2), "size": float64(2)}, body.Data)
+
+	code, body = serve(t, func(c *gin.Context) { Fail(c, errQuota.Wrap(errors.New("3/3 used"))) }, "fr, zh;q=0.5")
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.Equal(t, Body{Code: 10001, Message: "配额已用完", RequestID: "req-1"}, body)
+
+	// the middleware renders c.Error, anything unknown is an internal error
+	code, body = serve(t, func(c *gin.Context) { _ = c.Error(ErrNotFound.WithMessage("user %d not found", 7)) }, "zh")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, Body{Code: 40400, Message: "user 7 not found", RequestID: "req-1"}, body)
+	code, body = serve(t, func(c *gin.Context) { _ = c.Error(errors.New("db down")) }, "")
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Equal(t, Body{Code: 50000, Message: "internal server error", RequestID: "req-1"}, body)
+
+	assert.Len(t, logs.FilterMessage("response:GET / failed , error:db down").All(), 1)
+
+	// a response written by the handler is kept, the dropped internal errors are logged
+	code, _ = serve(t, func(c *gin.Context) {
+		_ = c.Error(ErrConflict)
+		_ = c.Error(errors.New("cache down"))
+		OK(c, nil)
+	}, "")
+	assert.Equal(t, http.StatusOK, code)
+	dropped := logs.FilterMessageSnippet("after the response was written").All()
+	if assert.Len(t, dropped, 1) {
+		assert.Equal(t, "response:GET / failed after the response was written , error:cache down", dropped[0].Message)
+	}
+}
+
+func TestError(t *testing.T) {
+	cause := errors.New("3/3 used")
+	err := errQuota.Wrap(cause).WithData(gin.H{"limit": 3})
+	assert.True(t, errors.Is(err, errQuota))
+	assert.True(t, errors.Is(err, cause))
+	assert.False(t, errors.Is(err, ErrForbidden))
+	assert.Equal(t, "code 10001:quota exceeded:3/3 used", err.Error())
+	// the copies leave the registered error alone
+	assert.Nil(t, errQuota.Data)
+	assert.Nil(t, errQuota.Unwrap())
+
+	assert.Panics(t, func() {
+		Register(10001, http.StatusForbidden, nil)
+	})
+	info, ok := Lookup(10001)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusForbidden, info.Status)
+	assert.Equal(t, "", Message(99999, "en"))
+}
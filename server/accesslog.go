@@ -0,0 +1,176 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"path"
+	"strings"
+	"time"
+
+	platform "github.com/chenxuan520/goweb-platform"
+	"github.com/chenxuan520/goweb-platform/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	accessLogFormatJson     = "json"
+	accessLogFormatCombined = "combined"
+	accessLogOutputFile     = "file"
+
+	defaultAccessLogBodySize = 4096
+	combinedTimeFormat       = "02/Jan/2006:15:04:05 -0700"
+)
+
+// bodyCaptureWriter keep the first bytes of the response body for the access log
+type bodyCaptureWriter struct {
+	gin.ResponseWriter
+	body  *bytes.Buffer
+	limit int
+}
+
+func (w *bodyCaptureWriter) Write(b []byte) (int, error) {
+	w.capture(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyCaptureWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *bodyCaptureWriter) capture(b []byte) {
+	if remain := w.limit - w.body.Len(); remain > 0 {
+		if len(b) > remain {
+			b = b[:remain]
+		}
+		w.body.Write(b)
+	}
+}
+
+// accessLog build the access log middleware from the access-log config section
+func (srv *ApiServer) accessLog(conf platform.AccessLog, logConf platform.Log) gin.HandlerFunc {
+	log := logger.GetLogger()
+	if conf.Output == accessLogOutputFile {
+		fileName := conf.Filename
+		if fileName == "" {
+			fileName = path.Join(logConf.Director, "access.log")
+		}
+		log = logger.NewAccessLogger(fileName, conf.Format, false)
+	}
+	return accessLogMiddleware(conf, log)
+}
+
+func accessLogMiddleware(conf platform.AccessLog, log *zap.Logger) gin.HandlerFunc {
+	maxBodySize := conf.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = defaultAccessLogBodySize
+	}
+	return func(c *gin.Context) {
+		if skipAccessLog(conf.SkipPaths, c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		var requestBody, responseBody *bytes.Buffer
+		if conf.CaptureBody {
+			requestBody = captureRequestBody(c, maxBodySize)
+			responseBody = &bytes.Buffer{}
+			c.Writer = &bodyCaptureWriter{ResponseWriter: c.Writer, body: responseBody, limit: maxBodySize}
+		}
+
+		start := time.Now()
+		c.Next()
+		latency := time.Since(start)
+
+		status := c.Writer.Status()
+		if status >= 200 && status < 300 && conf.SampleRate > 0 && conf.SampleRate < 1 && rand.Float64() >= conf.SampleRate {
+			return
+		}
+
+		if conf.Format == accessLogFormatCombined {
+			log.Info(combinedLine(c, start, latency))
+			return
+		}
+		fields := []zap.Field{
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.String("query", c.Request.URL.RawQuery),
+			zap.String("route", c.FullPath()),
+			zap.Int("status", status),
+			zap.Int("bytes", responseSize(c)),
+			zap.Duration("latency", latency),
+			zap.String("client_ip", c.ClientIP()),
+			zap.String("user_agent", c.Request.UserAgent()),
+			zap.String("referer", c.Request.Referer()),
+			zap.String("request_id", GetRequestID(c)),
+		}
+		if userID := c.GetString(UserIDKey); userID != "" {
+			fields = append(fields, zap.String("user_id", userID))
+		}
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate).String(); errs != "" {
+			fields = append(fields, zap.String("errors", errs))
+		}
+		if conf.CaptureBody {
+			fields = append(fields, zap.String("request_body", requestBody.String()), zap.String("response_body", responseBody.String()))
+		}
+		log.Info("access", fields...)
+	}
+}
+
+// captureRequestBody read at most limit bytes of the body and put them back for the handlers
+func captureRequestBody(c *gin.Context, limit int) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	if c.Request.Body == nil {
+		return buf
+	}
+	_, _ = io.CopyN(buf, c.Request.Body, int64(limit))
+	c.Request.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf.Bytes()), c.Request.Body), c.Request.Body}
+	return buf
+}
+
+func skipAccessLog(skipPaths []string, reqPath string) bool {
+	for _, skip := range skipPaths {
+		if strings.HasSuffix(skip, "*") {
+			if strings.HasPrefix(reqPath, strings.TrimSuffix(skip, "*")) {
+				return true
+			}
+		} else if skip == reqPath {
+			return true
+		}
+	}
+	return false
+}
+
+// combinedLine format the Apache combined log, followed by latency in seconds, request id and route
+func combinedLine(c *gin.Context, start time.Time, latency time.Duration) string {
+	user := c.GetString(UserIDKey)
+	if user == "" {
+		user = "-"
+	}
+	return fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %d %q %q %.3f %s %q",
+		c.ClientIP(), user, start.Format(combinedTimeFormat),
+		c.Request.Method, c.Request.URL.RequestURI(), c.Request.Proto,
+		c.Writer.Status(), responseSize(c), orDash(c.Request.Referer()), orDash(c.Request.UserAgent()),
+		latency.Seconds(), orDash(GetRequestID(c)), orDash(c.FullPath()))
+}
+
+func responseSize(c *gin.Context) int {
+	// gin reports -1 before anything is written
+	if size := c.Writer.Size(); size > 0 {
+		return size
+	}
+	return 0
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
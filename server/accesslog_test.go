@@ -0,0 +1,50 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	platform "github.com/chenxuan520/goweb-platform"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestAccessLog(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	core, logs := observer.New(zap.InfoLevel)
+	conf := platform.AccessLog{
+		SkipPaths:   []string{"/ping", "/static/*"},
+		CaptureBody: true,
+		MaxBodySize: 4,
+	}
+	engine := gin.New()
+	engine.Use(accessLogMiddleware(conf, zap.New(core)))
+	engine.POST("/users/:id", func(c *gin.Context) {
+		body, _ := c.GetRawData()
+		c.String(http.StatusCreated, string(body))
+	})
+	engine.GET("/ping", func(c *gin.Context) {})
+
+	for _, uri := range []string{"/ping", "/static/app.js"} {
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, uri, nil))
+	}
+	assert.Equal(t, 0, logs.Len())
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users/1", strings.NewReader("hello")))
+	// the handler still sees the whole body
+	assert.Equal(t, "hello", w.Body.String())
+
+	entries := logs.AllUntimed()
+	assert.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "/users/:id", fields["route"])
+	assert.Equal(t, int64(http.StatusCreated), fields["status"])
+	assert.Equal(t, int64(5), fields["bytes"])
+	assert.Equal(t, "hell", fields["request_body"])
+	assert.Equal(t, "hell", fields["response_body"])
+}
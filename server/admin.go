@@ -0,0 +1,79 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/chenxuan520/goweb-platform/logger"
+	"github.com/chenxuan520/goweb-platform/response"
+	"github.com/gin-gonic/gin"
+)
+
+const adminLogLevelURI = "/admin/log/level"
+
+type logLevelRequest struct {
+	Level    string `json:"level" binding:"required"`
+	Duration string `json:"duration"` // e.g. 10m, revert to the previous level afterwards
+}
+
+// adminHandler serve the admin endpoints on the health check port, away from the public
+// listeners and their CORS policies. It is nil unless system.admin-token is set
+func (srv *ApiServer) adminHandler() http.Handler {
+	if srv.Config == nil || srv.Config.System.AdminToken == "" {
+		return nil
+	}
+	engine := gin.New()
+	engine.Use(gin.Recovery())
+	admin := engine.Group("", adminAuth(srv.Config.System.AdminToken))
+	admin.GET(adminLogLevelURI, getLogLevel)
+	admin.PUT(adminLogLevelURI, setLogLevel)
+	return engine
+}
+
+func adminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		bearer := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+			response.Fail(c, response.ErrUnauthorized)
+			return
+		}
+		c.Next()
+	}
+}
+
+func getLogLevel(c *gin.Context) {
+	response.OK(c, gin.H{"level": logger.GetLevel()})
+}
+
+func setLogLevel(c *gin.Context) {
+	var req logLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, response.ErrBadRequest.WithMessage("%s", err.Error()))
+		return
+	}
+	var duration time.Duration
+	if req.Duration != "" {
+		var err error
+		if duration, err = time.ParseDuration(req.Duration); err != nil {
+			response.Fail(c, response.ErrBadRequest.WithMessage("%s", err.Error()))
+			return
+		}
+		if duration <= 0 {
+			response.Fail(c, response.ErrBadRequest.WithMessage("duration %s must be positive, omit it to keep the level", req.Duration))
+			return
+		}
+	}
+	if err := logger.SetLevelFor(req.Level, duration); err != nil {
+		response.Fail(c, response.ErrBadRequest.WithMessage("%s", err.Error()))
+		return
+	}
+	if duration > 0 {
+		logger.FromContext(c).Warn(fmt.Sprintf("admin:log level set to %s for %s", req.Level, duration))
+	} else {
+		logger.FromContext(c).Warn(fmt.Sprintf("admin:log level set to %s", req.Level))
+	}
+	response.OK(c, gin.H{"level": logger.GetLevel(), "duration": req.Duration})
+}
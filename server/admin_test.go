@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	platform "github.com/chenxuan520/goweb-platform"
+	"github.com/chenxuan520/goweb-platform/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestAdminLogLevel(t *testing.T) {
+	old := logger.SetLogger(zap.NewNop())
+	level := logger.GetLevel()
+	t.Cleanup(func() {
+		logger.SetLogger(old)
+		_ = logger.SetLevel(level)
+	})
+	gin.SetMode(gin.TestMode)
+
+	srv := &ApiServer{Config: &platform.Config{System: platform.System{AdminToken: "secret"}}}
+	// BuildEngine subscribe to the cors reloads
+	t.Cleanup(srv.cancelSubscriptions)
+	var body string
+	put := func(handler http.Handler, token, reqBody string) int {
+		req := httptest.NewRequest(http.MethodPut, adminLogLevelURI, strings.NewReader(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		body = w.Body.String()
+		return w.Code
+	}
+
+	admin := srv.adminHandler()
+	assert.Equal(t, http.StatusUnauthorized, put(admin, "wrong", `{"level": "error"}`))
+	assert.Contains(t, body, `"code":40100`)
+	assert.Equal(t, http.StatusBadRequest, put(admin, "secret", `{"level": "error", "duration": "-5m"}`))
+	assert.Contains(t, body, `"message":"duration -5m must be positive`)
+	assert.Equal(t, http.StatusBadRequest, put(admin, "secret", `{"level": "error", "duration": "0s"}`))
+	assert.Equal(t, level, logger.GetLevel())
+	assert.Equal(t, http.StatusOK, put(admin, "secret", `{"level": "error"}`))
+	assert.Contains(t, body, `"data":{"duration":"","level":"error"}`)
+	assert.Equal(t, "error", logger.GetLevel())
+
+	// the public engine does not serve the admin endpoints
+	assert.Equal(t, http.StatusNotFound, put(srv.BuildEngine(), "secret", `{"level": "warn"}`))
+	assert.Nil(t, (&ApiServer{Config: &platform.Config{}}).adminHandler())
+}
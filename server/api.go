@@ -5,22 +5,29 @@ import (
 	"fmt"
 	platform "github.com/chenxuan520/goweb-platform"
 	"github.com/chenxuan520/goweb-platform/logger"
+	"github.com/chenxuan520/goweb-platform/metrics"
 	"github.com/chenxuan520/goweb-platform/mongo"
 	"github.com/chenxuan520/goweb-platform/mysql"
 	"github.com/chenxuan520/goweb-platform/redis"
+	"github.com/chenxuan520/goweb-platform/response"
 	"github.com/gin-gonic/gin"
 	"github.com/jessevdk/go-flags"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
 
 const (
-	shutdownMaxAge = 15 * time.Second
-	shutdownWait   = 1000 * time.Millisecond
+	defaultShutdownTimeout = 15 * time.Second
+	defaultShutdownWait    = 1000 * time.Millisecond
+	// remoteConfigInterval is the poll interval of --config-url
+	remoteConfigInterval = 30 * time.Second
 )
 const (
 	green   = "\033[97;42m"
@@ -36,20 +43,46 @@ const (
 var (
 	ApiOptions struct {
 		flags.Options
-		Environment     string `short:"e" long:"env" description:"Use ApiServer environment" default:"testing"`
-		Version         bool   `short:"v" long:"verbose"  description:"Show ApiServer version"`
-		EnablePProfile  bool   `short:"p" long:"enable-pprof"  description:"enable pprof"`
-		PProfilePort    int    `short:"d" long:"pprof-port"  description:"pprof port" default:"8188"`
-		HealthCheckURI  string `short:"i" long:"health-check-uri"  description:"health check uri" default:"/health" `
-		HealthCheckPort int    `short:"f" long:"health-check-port"  description:"health check port" default:"8186"`
-		ConfigFileName  string `short:"c" long:"config" description:"Use ApiServer config file" default:"main"`
+		Environment     string   `short:"e" long:"env" description:"Use ApiServer environment, default ENVIRONMENT or testing"`
+		Version         bool     `short:"v" long:"verbose"  description:"Show ApiServer version"`
+		EnablePProfile  bool     `short:"p" long:"enable-pprof"  description:"enable pprof"`
+		PProfilePort    int      `short:"d" long:"pprof-port"  description:"pprof port" default:"8188"`
+		HealthCheckURI  string   `short:"i" long:"health-check-uri"  description:"health check uri" default:"/health" `
+		HealthCheckPort int      `short:"f" long:"health-check-port"  description:"health check port" default:"8186"`
+		EnableMetrics   bool     `short:"m" long:"enable-metrics"  description:"enable prometheus metrics"`
+		MetricsPort     int      `short:"t" long:"metrics-port"  description:"prometheus metrics port" default:"8187"`
+		ConfigFileName  string   `short:"c" long:"config" description:"Use ApiServer config file" default:"main"`
+		EnvPrefix       string   `long:"env-prefix" description:"environment variable prefix overriding config keys, e.g. APP_MYSQL_PASSWORD" default:"APP"`
+		Set             []string `long:"set" description:"override a config key, e.g. --set mysql.path=127.0.0.1, repeatable"`
+		CheckConfig     bool     `long:"check-config" description:"validate the config, print every invalid key and exit"`
+		SecretKeyFile   string   `long:"secret-key-file" description:"file holding the base64 key decrypting ENC(...) config values"`
+		ConfigURL       string   `long:"config-url" description:"poll a json or yaml config from this url on top of the files, cached in conf/<env>/<config>.remote.json"`
+
+		Secret secretCommand `command:"secret" description:"encrypt or decrypt ENC(...) config values"`
 	}
 )
 
 type Option func(c *platform.Config)
 
-func WithMysql() Option {
-	return func(c *platform.Config) {
+// ServerOption set up the server being built, such as its clients, health checks and hooks
+type ServerOption func(srv *ApiServer, c *platform.Config)
+
+// Applier is an Option or a ServerOption, New and NewApiServer accept both
+type Applier interface {
+	apply(srv *ApiServer, c *platform.Config)
+}
+
+func (o Option) apply(srv *ApiServer, c *platform.Config) {
+	o(c)
+}
+
+func (o ServerOption) apply(srv *ApiServer, c *platform.Config) {
+	o(srv, c)
+}
+
+// WithMysql init the default DB of the mysql package, process-global, and reload it with the config
+func WithMysql() ServerOption {
+	return func(srv *ApiServer, c *platform.Config) {
 		mysqlConfig := c.Mysql
 		//db
 		dsn := mysqlConfig.EmptyDsn()
@@ -57,30 +90,65 @@ func WithMysql() Option {
 		if err := mysql.CreateDatabase(dsn, "mysql", createSql); err != nil {
 			logger.GetLogger().Error(fmt.Sprintf("create mysql database failed , error:%s", err.Error()))
 		}
-		_, err := mysql.Init(mysqlConfig.Dsn())
+		db, err := mysql.Init(mysqlConfig.Dsn())
 		if err != nil {
 			logger.GetLogger().Error(fmt.Sprintf("api-server:init mysql failed , error:%s", err.Error()))
 		} else {
 			logger.GetLogger().Info("api-server:init mysql success")
+			if err := metrics.RegisterGormDB("mysql", db); err != nil {
+				logger.GetLogger().Error(fmt.Sprintf("api-server:register mysql metrics failed , error:%s", err.Error()))
+			}
 		}
+		srv.RegisterHealthChecker(NewHealthChecker("mysql", mysqlHealthCheck))
+		srv.RegisterHook(clientHook("mysql", mysql.Close))
+		srv.onChange("mysql", func(old, cur *platform.Config) {
+			db, err := mysql.Reload(cur.Mysql.Dsn())
+			if err != nil {
+				logger.GetLogger().Error(fmt.Sprintf("api-server:reload mysql failed , keep the old connection , error:%s", err.Error()))
+				return
+			}
+			logger.GetLogger().Info("api-server:reload mysql success")
+			if err := metrics.RegisterGormDB("mysql", db); err != nil {
+				logger.GetLogger().Error(fmt.Sprintf("api-server:register mysql metrics failed , error:%s", err.Error()))
+			}
+		})
 	}
 }
 
-func WithRedis() Option {
-	return func(c *platform.Config) {
+// WithRedis init the default client of the redis package, process-global, and reload it with the config
+func WithRedis() ServerOption {
+	return func(srv *ApiServer, c *platform.Config) {
 		redisConfig := c.Redis
 		//reds
-		_, err := redis.Init(redisConfig.Addr, redisConfig.Password, redisConfig.DB)
+		client, err := redis.Init(redisConfig.Addr, redisConfig.Password, redisConfig.DB)
 		if err != nil {
 			logger.GetLogger().Error(fmt.Sprintf("api-server:init redis failed , error:%s", err.Error()))
 		} else {
 			logger.GetLogger().Info("api-server:init redis success")
 		}
+		if err := metrics.RegisterRedis("redis", client); err != nil {
+			logger.GetLogger().Error(fmt.Sprintf("api-server:register redis metrics failed , error:%s", err.Error()))
+		}
+		srv.RegisterHealthChecker(NewHealthChecker("redis", redisHealthCheck))
+		srv.RegisterHook(clientHook("redis", redis.Close))
+		srv.onChange("redis", func(old, cur *platform.Config) {
+			redisConfig := cur.Redis
+			client, err := redis.Reload(redisConfig.Addr, redisConfig.Password, redisConfig.DB)
+			if err != nil {
+				logger.GetLogger().Error(fmt.Sprintf("api-server:reload redis failed , keep the old client , error:%s", err.Error()))
+				return
+			}
+			logger.GetLogger().Info("api-server:reload redis success")
+			if err := metrics.RegisterRedis("redis", client); err != nil {
+				logger.GetLogger().Error(fmt.Sprintf("api-server:register redis metrics failed , error:%s", err.Error()))
+			}
+		})
 	}
 }
 
-func WithMongo() Option {
-	return func(c *platform.Config) {
+// WithMongo init the default session of the mongo package, process-global
+func WithMongo() ServerOption {
+	return func(srv *ApiServer, c *platform.Config) {
 		mongoConfig := c.Mongo
 		err := mongo.Init(mongoConfig.Host, mongoConfig.Port, mongoConfig.DBname, mongoConfig.User, mongoConfig.Password)
 		if err != nil {
@@ -88,19 +156,107 @@ func WithMongo() Option {
 		} else {
 			logger.GetLogger().Info("api-server:init mongo success")
 		}
+		srv.RegisterHealthChecker(NewHealthChecker("mongo", mongoHealthCheck))
+		srv.RegisterHook(clientHook("mongo", mongo.Close))
+	}
+}
+
+// WithHealthCheck serve the health checks on port under uri, a port <= 0 disables them
+func WithHealthCheck(uri string, port int) ServerOption {
+	return func(srv *ApiServer, c *platform.Config) {
+		srv.HealthCheckURI = uri
+		srv.HealthCheckPort = port
+	}
+}
+
+// WithEnvironment tell the server the env it runs in, e.g. the cors allow-all is for testing only
+func WithEnvironment(env platform.Environment) ServerOption {
+	return func(srv *ApiServer, c *platform.Config) {
+		srv.Environment = env
+	}
+}
+
+// WithMetrics serve the prometheus metrics on port and count the requests, a port <= 0 disables them
+func WithMetrics(port int) ServerOption {
+	return func(srv *ApiServer, c *platform.Config) {
+		srv.MetricsPort = port
+	}
+}
+
+// clientHook close a client package once the requests are drained. It is named after
+// the client and has ClientHookPriority, so the application hooks stop before it
+func clientHook(name string, closeFn func() error) Hook {
+	return Hook{Name: name, Priority: ClientHookPriority, OnStop: func(ctx context.Context) error {
+		return closeFn()
+	}}
+}
+
+// onChange subscribe fn with platform.OnChange until the server stops, the first
+// subscription registers the hook cancelling them all
+func (srv *ApiServer) onChange(section string, fn func(old, cur *platform.Config)) {
+	cancel := platform.OnChange(section, fn)
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if !srv.subscriptionHook {
+		srv.subscriptionHook = true
+		srv.RegisterHook(Hook{Name: "config-subscriptions", OnStop: func(ctx context.Context) error {
+			srv.cancelSubscriptions()
+			return nil
+		}})
+	}
+	srv.subscriptions = append(srv.subscriptions, cancel)
+}
+
+// cancelSubscriptions cancel the config reload subscriptions of the server
+func (srv *ApiServer) cancelSubscriptions() {
+	srv.mu.Lock()
+	cancels := srv.subscriptions
+	srv.subscriptions = nil
+	srv.mu.Unlock()
+	for _, cancel := range cancels {
+		cancel()
+	}
+}
+
+// WithSection register an application config section, see platform.RegisterSection
+func WithSection(name string, ptr interface{}) Option {
+	return func(c *platform.Config) {
+		if err := platform.RegisterSection(name, ptr); err != nil {
+			logger.GetLogger().Error(fmt.Sprintf("api-server:load config section %s failed , error:%s", name, err.Error()))
+		}
 	}
 }
 
 type ApiServer struct {
-	Engine      *gin.Engine
-	HttpServer  *http.Server
-	Addr        string
-	mu          sync.Mutex
-	doneChan    chan struct{}
-	Routers     []func(*gin.Engine)
-	Middlewares []func(*gin.Engine)
-	Shutdowns   []func(*ApiServer)
-	Services    []func(*ApiServer)
+	Config          *platform.Config
+	Engine          *gin.Engine
+	HttpServer      *http.Server
+	HealthServer    *http.Server
+	MetricsServer   *http.Server
+	Addr            string
+	HealthCheckURI  string
+	HealthCheckPort int
+	MetricsPort     int
+	mu              sync.Mutex
+	doneChan        chan struct{}
+	stopping        int32
+	shuttingDown    int32
+	restarting      int32
+	listeners       map[string]net.Listener
+	listenerServers []*http.Server
+	healthCheckers  []*registeredChecker
+	Routers         []func(*gin.Engine)
+	Middlewares     []func(*gin.Engine)
+	Shutdowns       []func(*ApiServer)
+	Services        []func(*ApiServer)
+	Lifecycle       Lifecycle
+	// Environment is the env the server runs in, see WithEnvironment
+	Environment platform.Environment
+	corsState   corsState
+	// subscriptions cancel the config reload subscriptions of the server, on stop
+	subscriptions    []func()
+	subscriptionHook bool
+	shutdownDone     chan struct{}
 }
 
 //get close Chan
@@ -117,7 +273,43 @@ func (srv *ApiServer) getDoneChanLocked() chan struct{} {
 	return srv.doneChan
 }
 
+// getShutdownDone is closed once Shutdown has stopped the servers and the hooks
+func (srv *ApiServer) getShutdownDone() chan struct{} {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.shutdownDone == nil {
+		srv.shutdownDone = make(chan struct{})
+	}
+	return srv.shutdownDone
+}
+
+func (srv *ApiServer) closeDoneChan() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	ch := srv.getDoneChanLocked()
+	select {
+	case <-ch:
+	default:
+		close(ch)
+	}
+}
+
 func (srv *ApiServer) Shutdown(ctx context.Context) {
+	srv.shutdown(ctx, false)
+}
+
+// shutdown stop the server, on a restart handoff the child already serves on the shared
+// listeners so readiness stays up and shutdownWait is skipped
+func (srv *ApiServer) shutdown(ctx context.Context, handoff bool) {
+	if !atomic.CompareAndSwapInt32(&srv.stopping, 0, 1) {
+		return
+	}
+	// fail readiness first so the load balancer stops routing during shutdownWait
+	if !handoff {
+		atomic.StoreInt32(&srv.shuttingDown, 1)
+	}
+	srv.closeDoneChan()
+	defer close(srv.getShutdownDone())
 	//Give priority to business shutdown Hook
 	if len(srv.Shutdowns) > 0 {
 		for _, shutdown := range srv.Shutdowns {
@@ -125,24 +317,50 @@ func (srv *ApiServer) Shutdown(ctx context.Context) {
 		}
 	}
 	//wait for registry shutdown
-	select {
-	case <-time.After(shutdownWait):
+	if !handoff {
+		select {
+		case <-time.After(srv.shutdownWait()):
+		case <-ctx.Done():
+		}
 	}
 	// close the HttpServer
-	srv.HttpServer.Shutdown(ctx)
+	if srv.HttpServer != nil {
+		srv.HttpServer.Shutdown(ctx)
+	}
+	srv.mu.Lock()
+	listenerServers := srv.listenerServers
+	srv.mu.Unlock()
+	for _, server := range listenerServers {
+		server.Shutdown(ctx)
+	}
+	// keep liveness answering until the requests are drained
+	if srv.HealthServer != nil {
+		srv.HealthServer.Shutdown(ctx)
+	}
+	if srv.MetricsServer != nil {
+		srv.MetricsServer.Shutdown(ctx)
+	}
+	// the dependencies go last, in reverse order of their start
+	srv.stopHooks(ctx)
 }
 
 func (srv *ApiServer) setupSignal() {
 	go func() {
 		var sigChan = make(chan os.Signal, 1)
-		signal.Notify(sigChan /*syscall.SIGUSR1,*/, syscall.SIGINT, syscall.SIGHUP, syscall.SIGTERM)
-		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownMaxAge)
-		defer shutdownCancel()
-
+		signal.Notify(sigChan, append([]os.Signal{syscall.SIGINT, syscall.SIGHUP, syscall.SIGTERM}, restartSignals...)...)
 		for sig := range sigChan {
 			if sig == syscall.SIGINT || sig == syscall.SIGHUP || sig == syscall.SIGTERM {
 				logger.GetLogger().Error(fmt.Sprintf("Graceful shutdown:signal %v to stop api-server ", sig))
+				shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), srv.shutdownTimeout())
 				srv.Shutdown(shutdownCtx)
+				shutdownCancel()
+			} else if isRestartSignal(sig) {
+				logger.GetLogger().Info(fmt.Sprintf("Hot restart:signal %v to fork api-server", sig))
+				go func() {
+					if err := srv.Restart(); err != nil {
+						logger.GetLogger().Error(fmt.Sprintf("api-server:hot restart failed , error:%s", err.Error()))
+					}
+				}()
 			} else {
 				logger.GetLogger().Info(fmt.Sprintf("Caught signal %v", sig))
 			}
@@ -151,15 +369,45 @@ func (srv *ApiServer) setupSignal() {
 	}()
 }
 
-func NewApiServer(opts ...Option) (*ApiServer, error) {
+// New build an ApiServer from cfg and opts alone. It neither parses the process
+// arguments nor touches the signals, the logger or the gin mode, so it suits embedding
+// and tests. The clients of WithMysql, WithRedis and WithMongo are process-global, use
+// them in one server per process. The config reload subscriptions of the server end with
+// its stop hooks. The health and metrics servers are off unless an option sets their port
+func New(cfg *platform.Config, opts ...Applier) (*ApiServer, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("api-server:nil config")
+	}
+	apiServer := &ApiServer{
+		Config: cfg,
+		Addr:   fmt.Sprintf(":%d", cfg.System.Addr),
+	}
+	for _, opt := range opts {
+		opt.apply(apiServer, cfg)
+	}
+	return apiServer, nil
+}
+
+// NewApiServer build an ApiServer the command line way: it parses os.Args into ApiOptions,
+// loads the config of the environment, sets up the logger, the gin mode and the signals,
+// then hands over to New. It exits the process on --help, --verbose, --check-config and subcommands
+func NewApiServer(opts ...Applier) (*ApiServer, error) {
 	var parser = flags.NewParser(&ApiOptions, flags.Default)
+	parser.SubcommandsOptional = true
 	if _, err := parser.Parse(); err != nil {
 		if flagsErr, ok := err.(*flags.Error); ok && flagsErr.Type == flags.ErrHelp {
 			os.Exit(0)
 		}
+		if parser.Active != nil {
+			os.Exit(1)
+		}
 
 		return nil, err
 	}
+	// a subcommand such as secret has done its job, do not start the server
+	if parser.Active != nil {
+		os.Exit(0)
+	}
 
 	if ApiOptions.Version {
 		//TODO
@@ -167,60 +415,102 @@ func NewApiServer(opts ...Option) (*ApiServer, error) {
 		os.Exit(0)
 	}
 
-	if ApiOptions.EnablePProfile {
+	env, err := platform.ResolveEnvironment(ApiOptions.Environment)
+	if err != nil {
+		return nil, err
+	}
+	envDefaults := env.Defaults()
+
+	if ApiOptions.EnablePProfile || envDefaults.EnablePProf {
 		go func() {
 			fmt.Printf("enable pprof http server at:%d\n", ApiOptions.PProfilePort)
-			fmt.Println(http.ListenAndServe(fmt.Sprintf(":%d", ApiOptions.PProfilePort), nil))
+			fmt.Println(http.ListenAndServe(fmt.Sprintf(":%d", ApiOptions.PProfilePort), pprofHandler()))
 		}()
 	}
 
-	var env = platform.Environment(ApiOptions.Environment)
-	if env.Invalid() {
-		var err error
-		env, err = platform.NewGlobalEnvironment()
-		if err != nil {
-			return nil, err
-		}
-	}
-
 	var configFile = ApiOptions.ConfigFileName
 	if configFile == "" {
 		configFile = "main"
 	}
-	defaultConfig, err := platform.LoadConfig(env.String(), configFile)
+	loadOpts := []platform.LoadOption{platform.WithEnvPrefix(ApiOptions.EnvPrefix), platform.WithOverrides(ApiOptions.Set...)}
+	if ApiOptions.SecretKeyFile != "" {
+		loadOpts = append(loadOpts, platform.WithSecretKeyFile(ApiOptions.SecretKeyFile))
+	}
+	if ApiOptions.ConfigURL != "" {
+		cache := path.Join("conf", env.String(), configFile+".remote.json")
+		loadOpts = append(loadOpts, platform.WithSources(platform.NewFileSource(env.String(), configFile),
+			platform.CachedSource(platform.NewHTTPSource(ApiOptions.ConfigURL, remoteConfigInterval), cache)))
+	}
+	if ApiOptions.CheckConfig {
+		if err := platform.CheckConfig(env.String(), configFile, loadOpts...); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		fmt.Printf("config %s of env %s is valid\n", configFile, env.String())
+		os.Exit(0)
+	}
+	defaultConfig, err := platform.LoadConfig(env.String(), configFile, loadOpts...)
 	if err != nil {
 		fmt.Printf("api-server:init config error:%s", err.Error())
 		return nil, err
 	}
 	logConfig := defaultConfig.Log
+	if logConfig.Level == "" {
+		logConfig.Level = envDefaults.LogLevel
+	}
 	//log
 	logger.Init(logConfig.Level, logConfig.Format, logConfig.Prefix, logConfig.Director, logConfig.ShowLine, logConfig.EncodeLevel, logConfig.StacktraceKey, logConfig.LogInConsole)
 
-	if len(opts) > 0 {
-		for _, opt := range opts {
-			opt(defaultConfig)
-		}
+	serverOpts := []Applier{WithEnvironment(env), WithHealthCheck(ApiOptions.HealthCheckURI, ApiOptions.HealthCheckPort)}
+	if ApiOptions.EnableMetrics {
+		serverOpts = append(serverOpts, WithMetrics(ApiOptions.MetricsPort))
 	}
-	apiServer := &ApiServer{
-		Addr: fmt.Sprintf(":%d", defaultConfig.System.Addr),
+	apiServer, err := New(defaultConfig, append(serverOpts, opts...)...)
+	if err != nil {
+		return nil, err
 	}
+	apiServer.onChange("log", func(old, cur *platform.Config) {
+		if old.Log.Level == cur.Log.Level {
+			return
+		}
+		level := cur.Log.Level
+		if level == "" {
+			level = envDefaults.LogLevel
+		}
+		if err := logger.SetLevel(level); err != nil {
+			logger.GetLogger().Error(fmt.Sprintf("api-server:reload log level failed , error:%s", err.Error()))
+		}
+	})
 
 	apiServer.setupSignal()
 	//set gin mode
-	switch env {
-	case platform.EnvProduction:
-		gin.SetMode(gin.ReleaseMode)
-	case platform.EnvTesting:
-		gin.SetMode(gin.DebugMode)
+	if envDefaults.GinMode != "" {
+		gin.SetMode(envDefaults.GinMode)
 	}
 	return apiServer, nil
 }
 
-// ListenAndServe Listen And Serve()
-func (srv *ApiServer) ListenAndServe() error {
+// BuildEngine build srv.Engine: the platform middlewares, then the registered
+// Services, Middlewares and Routers
+func (srv *ApiServer) BuildEngine() *gin.Engine {
 	srv.Engine = gin.New()
+	// let handlers pass *gin.Context wherever a context.Context is expected
+	srv.Engine.ContextWithFallback = true
+	srv.Engine.Use(srv.requestID())
+	srv.Engine.Use(srv.requestLogger())
+	if srv.Config != nil && srv.Config.AccessLog.Enable {
+		srv.Engine.Use(srv.accessLog(srv.Config.AccessLog, srv.Config.Log))
+	}
+	if srv.MetricsPort > 0 {
+		srv.Engine.Use(metrics.GinMiddleware())
+	}
 	srv.Engine.Use(srv.apiRecoveryMiddleware())
+	srv.Engine.Use(response.Middleware())
+	srv.watchCorsConfig()
 	srv.Engine.Use(srv.cors())
+	if srv.Config != nil && srv.Config.System.MaxBodySize > 0 {
+		srv.Engine.Use(maxBodySize(srv.Config.System.MaxBodySize))
+	}
 
 	for _, service := range srv.Services {
 		service(srv)
@@ -233,18 +523,39 @@ func (srv *ApiServer) ListenAndServe() error {
 	for _, c := range srv.Routers {
 		c(srv.Engine)
 	}
+	return srv.Engine
+}
+
+// ListenAndServe Listen And Serve()
+func (srv *ApiServer) ListenAndServe() error {
+	srv.BuildEngine()
 
-	srv.HttpServer = &http.Server{
-		Handler:        srv.Engine,
-		Addr:           srv.Addr,
-		ReadTimeout:    20 * time.Second,
-		WriteTimeout:   20 * time.Second,
-		MaxHeaderBytes: 1 << 20,
+	// the dependencies are up before the first request is accepted
+	if err := srv.startHooks(context.Background()); err != nil {
+		return err
+	}
+	srv.HttpServer = srv.NewHttpServer(srv.Engine, srv.Addr)
+	ln, err := srv.listen("tcp", srv.Addr)
+	if err == nil {
+		if err = srv.startListeners(srv.Engine); err != nil {
+			ln.Close()
+		}
 	}
+	if err != nil {
+		srv.stopHooks(context.Background())
+		return err
+	}
+	srv.startHealthServer()
+	srv.startMetricsServer()
+	srv.notifyReady()
 	logger.GetLogger().Info(fmt.Sprintf("api-server port run on %s ", srv.Addr))
-	if err := srv.HttpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	if err := srv.HttpServer.Serve(ln); err != nil && err != http.ErrServerClosed {
 		return err
 	}
+	// Serve return as soon as Shutdown starts, wait for the drain and the stop hooks
+	if srv.isStopping() {
+		<-srv.getShutdownDone()
+	}
 	return nil
 }
 
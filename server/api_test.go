@@ -0,0 +1,45 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	platform "github.com/chenxuan520/goweb-platform"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	_, err := New(nil)
+	assert.NotNil(t, err)
+
+	var applied []*platform.Config
+	record := ServerOption(func(srv *ApiServer, c *platform.Config) {
+		applied = append(applied, c)
+	})
+	first, err := New(&platform.Config{System: platform.System{Addr: 8080}}, WithHealthCheck("/ping", 9001), record)
+	assert.Nil(t, err)
+	// the config only Option of the first releases still applies
+	tune := Option(func(c *platform.Config) {
+		c.System.Version = "v2"
+	})
+	second, err := New(&platform.Config{System: platform.System{Addr: 8081}}, WithMetrics(9002), tune, record)
+	assert.Nil(t, err)
+
+	assert.Equal(t, ":8080", first.Addr)
+	assert.Equal(t, "/ping", first.HealthCheckURI)
+	assert.Equal(t, 9001, first.HealthCheckPort)
+	assert.Equal(t, 0, first.MetricsPort)
+	assert.Equal(t, ":8081", second.Addr)
+	assert.Equal(t, 0, second.HealthCheckPort)
+	assert.Equal(t, 9002, second.MetricsPort)
+	assert.Equal(t, "v2", second.Config.System.Version)
+	assert.Equal(t, []*platform.Config{first.Config, second.Config}, applied)
+}
+
+func TestPProfHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	pprofHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "goroutine")
+}
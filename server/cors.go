@@ -0,0 +1,253 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	platform "github.com/chenxuan520/goweb-platform"
+	"github.com/chenxuan520/goweb-platform/logger"
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	defaultCorsMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodHead}
+	defaultCorsHeaders = []string{"Content-Type", "AccessToken", "X-CSRF-Token", "Authorization", "Token", "X-User-Id", "X-Request-ID"}
+	defaultCorsExpose  = []string{"Content-Length", "Content-Type", "X-Request-ID"}
+)
+
+// corsPolicy is a platform.CorsPolicy ready to answer
+type corsPolicy struct {
+	prefix      string
+	any         bool
+	origins     map[string]bool
+	patterns    []*regexp.Regexp
+	methods     string
+	headers     string
+	expose      string
+	credentials bool
+	maxAge      string
+	// reflect answer any origin with credentials, the allow-all of testing
+	reflect bool
+}
+
+// corsState hold the policies of a server, compiled on the first request after a change
+type corsState struct {
+	mu sync.RWMutex
+	// config is the cors section of the last reload, nil for the one of srv.Config
+	config     *platform.Cors
+	registered []platform.CorsPolicy
+	compiled   []*corsPolicy
+	ready      bool
+}
+
+// RegisterCorsPolicy Register CORS policies besides those of the cors config, such as one
+// per route group, at any time: a Routers callback may register the policy of its group.
+// The policy of the longest prefix matching the path applies
+func (srv *ApiServer) RegisterCorsPolicy(policies ...platform.CorsPolicy) {
+	srv.corsState.mu.Lock()
+	defer srv.corsState.mu.Unlock()
+	srv.corsState.registered = append(srv.corsState.registered, policies...)
+	srv.corsState.ready = false
+}
+
+// setCorsConfig replace the cors section, on a config reload
+func (srv *ApiServer) setCorsConfig(conf platform.Cors) {
+	srv.corsState.mu.Lock()
+	defer srv.corsState.mu.Unlock()
+	srv.corsState.config = &conf
+	srv.corsState.ready = false
+}
+
+// watchCorsConfig subscribe the cors middleware to the reloads of the cors section
+func (srv *ApiServer) watchCorsConfig() {
+	srv.onChange("cors", func(old, cur *platform.Config) {
+		srv.setCorsConfig(cur.Cors)
+	})
+}
+
+// currentCorsPolicies return the compiled policies, compiling them after a change
+func (srv *ApiServer) currentCorsPolicies() []*corsPolicy {
+	state := &srv.corsState
+	state.mu.RLock()
+	if state.ready {
+		defer state.mu.RUnlock()
+		return state.compiled
+	}
+	state.mu.RUnlock()
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if !state.ready {
+		conf := state.config
+		if conf == nil && srv.Config != nil {
+			conf = &srv.Config.Cors
+		}
+		state.compiled = srv.compileCorsPolicies(conf, state.registered)
+		state.ready = true
+	}
+	return state.compiled
+}
+
+// newCorsPolicy compile p, a wildcard such as https://*.example.com matches any subdomain
+func newCorsPolicy(p platform.CorsPolicy) (*corsPolicy, error) {
+	policy := &corsPolicy{
+		prefix:      "/" + strings.Trim(p.Prefix, "/"),
+		origins:     make(map[string]bool),
+		methods:     strings.Join(orDefaultList(p.AllowMethods, defaultCorsMethods), ", "),
+		headers:     strings.Join(orDefaultList(p.AllowHeaders, defaultCorsHeaders), ", "),
+		expose:      strings.Join(orDefaultList(p.ExposeHeaders, defaultCorsExpose), ", "),
+		credentials: p.AllowCredentials,
+	}
+	if p.MaxAge > 0 {
+		policy.maxAge = strconv.Itoa(int(p.MaxAge.Seconds()))
+	}
+	for _, origin := range p.AllowOrigins {
+		switch {
+		case origin == "*":
+			policy.any = true
+		case strings.HasPrefix(origin, "~"):
+			// the pattern matches the whole origin, https://a.example.com.attacker.io is no subdomain
+			re, err := regexp.Compile("(?i)^(?:" + origin[1:] + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("cors origin %s:%w", origin, err)
+			}
+			policy.patterns = append(policy.patterns, re)
+		case strings.Contains(origin, "*"):
+			pattern := strings.ReplaceAll(regexp.QuoteMeta(strings.ToLower(origin)), `\*`, `[a-z0-9-]+(\.[a-z0-9-]+)*`)
+			policy.patterns = append(policy.patterns, regexp.MustCompile("^"+pattern+"$"))
+		default:
+			policy.origins[strings.ToLower(origin)] = true
+		}
+	}
+	if policy.any && policy.credentials {
+		return nil, fmt.Errorf("cors origin * with credentials, browsers reject it")
+	}
+	return policy, nil
+}
+
+func orDefaultList(list, def []string) []string {
+	if len(list) == 0 {
+		return def
+	}
+	return list
+}
+
+// allowed tell whether origin may read the response, scheme and host are case insensitive
+func (p *corsPolicy) allowed(origin string) bool {
+	origin = strings.ToLower(origin)
+	if p.reflect || p.any || p.origins[origin] {
+		return true
+	}
+	for _, re := range p.patterns {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// match tell whether the policy covers path, a prefix matches whole segments only
+func (p *corsPolicy) match(path string) bool {
+	return p.prefix == "/" || path == p.prefix || strings.HasPrefix(path, p.prefix+"/")
+}
+
+// compileCorsPolicies compile the policies of conf and the registered ones, the longest prefix first.
+// The allow-all of conf is honoured in testing only
+func (srv *ApiServer) compileCorsPolicies(conf *platform.Cors, registered []platform.CorsPolicy) []*corsPolicy {
+	var configs []platform.CorsPolicy
+	if conf != nil {
+		if conf.AllowAll {
+			if srv.Environment == platform.EnvTesting {
+				logger.GetLogger().Warn("api-server:cors allows every origin with credentials, testing only")
+				return []*corsPolicy{{prefix: "/", reflect: true, credentials: true,
+					methods: strings.Join(append(defaultCorsMethods, http.MethodOptions), ", "),
+					headers: strings.Join(defaultCorsHeaders, ", "), expose: strings.Join(defaultCorsExpose, ", ")}}
+			}
+			logger.GetLogger().Error(fmt.Sprintf("api-server:cors allow-all ignored in env %q, it is for testing only", srv.Environment))
+		}
+		configs = append(configs, conf.Policies...)
+	}
+	configs = append(configs, registered...)
+
+	policies := make([]*corsPolicy, 0, len(configs))
+	for _, c := range configs {
+		policy, err := newCorsPolicy(c)
+		if err != nil {
+			logger.GetLogger().Error(fmt.Sprintf("api-server:skip cors policy of %q , error:%s", c.Prefix, err.Error()))
+			continue
+		}
+		policies = append(policies, policy)
+	}
+	sort.SliceStable(policies, func(i, j int) bool {
+		return len(policies[i].prefix) > len(policies[j].prefix)
+	})
+	return policies
+}
+
+// cors answer the preflights and add the CORS headers to the requests of an allowed origin.
+// Without a policy no CORS header is sent, so the browsers keep the same-origin policy.
+// A reload of the cors section, see watchCorsConfig, applies to the next requests
+func (srv *ApiServer) cors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var policy *corsPolicy
+		for _, p := range srv.currentCorsPolicies() {
+			if p.match(c.Request.URL.Path) {
+				policy = p
+				break
+			}
+		}
+		if policy == nil {
+			c.Next()
+			return
+		}
+
+		header := c.Writer.Header()
+		// the answer depends on the origin unless every origin gets *
+		if !policy.any || policy.reflect {
+			header.Add("Vary", "Origin")
+		}
+		origin := c.GetHeader("Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if preflight {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+		}
+		if origin == "" {
+			c.Next()
+			return
+		}
+		if !policy.allowed(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if policy.any && !policy.reflect {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+			if policy.credentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+		if !preflight {
+			header.Set("Access-Control-Expose-Headers", policy.expose)
+			c.Next()
+			return
+		}
+		header.Set("Access-Control-Allow-Methods", policy.methods)
+		header.Set("Access-Control-Allow-Headers", policy.headers)
+		if policy.maxAge != "" {
+			header.Set("Access-Control-Max-Age", policy.maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	platform "github.com/chenxuan520/goweb-platform"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func corsRequest(engine *gin.Engine, method, path, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if method == http.MethodOptions {
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func corsEngine(srv *ApiServer) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(srv.cors())
+	engine.Any("/*path", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return engine
+}
+
+func TestCors(t *testing.T) {
+	initTestLogger(t)
+
+	// no policy, no CORS header
+	w := corsRequest(corsEngine(&ApiServer{Config: &platform.Config{}}), http.MethodGet, "/users", "https://evil.com")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	srv := &ApiServer{Config: &platform.Config{Cors: platform.Cors{Policies: []platform.CorsPolicy{{
+		AllowOrigins:     []string{"https://app.example.com", "https://*.example.org", `~https://.*\.example\.net`},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}}}}}
+	engine := corsEngine(srv)
+	// registered once the engine is built, e.g. by a Routers callback
+	srv.RegisterCorsPolicy(platform.CorsPolicy{Prefix: "/open", AllowOrigins: []string{"*"}, AllowMethods: []string{"GET"}})
+	// * with credentials is skipped
+	srv.RegisterCorsPolicy(platform.CorsPolicy{Prefix: "/open/private", AllowOrigins: []string{"*"}, AllowCredentials: true})
+
+	for _, origin := range []string{"https://app.example.com", "https://a.b.example.org", "https://shop.example.net", "https://APP.example.com", "https://App.example.org", "https://Shop.Example.net"} {
+		w = corsRequest(engine, http.MethodGet, "/users", origin)
+		assert.Equal(t, origin, w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Equal(t, "Origin", w.Header().Get("Vary"))
+	}
+	for _, origin := range []string{"https://evil.com", "https://example.org", "https://evil-example.org", "http://app.example.com", "https://shop.example.net.evil.com", "https://x.example.net.attacker.io"} {
+		w = corsRequest(engine, http.MethodGet, "/users", origin)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"), origin)
+		// a cache must not serve this answer to an allowed origin
+		assert.Equal(t, "Origin", w.Header().Get("Vary"))
+	}
+
+	w = corsRequest(engine, http.MethodOptions, "/users", "https://app.example.com")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+	assert.Equal(t, "GET, POST, PUT, PATCH, DELETE, HEAD", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}, w.Header().Values("Vary"))
+	w = corsRequest(engine, http.MethodOptions, "/users", "https://evil.com")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// the group policy takes over under its prefix, * never goes with credentials
+	w = corsRequest(engine, http.MethodOptions, "/open/items", "https://evil.com")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "GET", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Empty(t, w.Header().Get("Access-Control-Max-Age"))
+	w = corsRequest(engine, http.MethodGet, "/openapi", "https://evil.com")
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	w = corsRequest(engine, http.MethodGet, "/open/private", "https://evil.com")
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+
+	// a reload of the cors section applies to the next request
+	srv.setCorsConfig(platform.Cors{Policies: []platform.CorsPolicy{{AllowOrigins: []string{"https://new.example.com"}}}})
+	w = corsRequest(engine, http.MethodGet, "/users", "https://app.example.com")
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	w = corsRequest(engine, http.MethodGet, "/users", "https://new.example.com")
+	assert.Equal(t, "https://new.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCorsAllowAll(t *testing.T) {
+	initTestLogger(t)
+	conf := &platform.Config{Cors: platform.Cors{AllowAll: true}}
+
+	w := corsRequest(corsEngine(&ApiServer{Config: conf, Environment: platform.EnvTesting}), http.MethodGet, "/users", "https://any.com")
+	assert.Equal(t, "https://any.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "Origin", w.Header().Get("Vary"))
+
+	w = corsRequest(corsEngine(&ApiServer{Config: conf, Environment: platform.EnvProduction}), http.MethodGet, "/users", "https://any.com")
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
@@ -0,0 +1,329 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chenxuan520/goweb-platform/logger"
+	"github.com/chenxuan520/goweb-platform/mongo"
+	"github.com/chenxuan520/goweb-platform/mysql"
+	"github.com/chenxuan520/goweb-platform/redis"
+)
+
+const (
+	healthCheckTimeout  = 3 * time.Second
+	healthCheckInterval = 10 * time.Second
+
+	HealthStatusUp       = "UP"
+	HealthStatusDown     = "DOWN"
+	HealthStatusDegraded = "DEGRADED"
+	HealthStatusUnknown  = "UNKNOWN"
+)
+
+// HealthChecker is a dependency which readiness depends on
+type HealthChecker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+type healthCheckerFunc struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+func (h *healthCheckerFunc) Name() string {
+	return h.name
+}
+
+func (h *healthCheckerFunc) Check(ctx context.Context) error {
+	return h.check(ctx)
+}
+
+// NewHealthChecker wrap a plain function as HealthChecker
+func NewHealthChecker(name string, check func(ctx context.Context) error) HealthChecker {
+	return &healthCheckerFunc{name: name, check: check}
+}
+
+// HealthCheckOption tune how a registered checker is run
+type HealthCheckOption func(c *registeredChecker)
+
+// WithCheckTimeout limit a single run of the checker, default 3s
+func WithCheckTimeout(timeout time.Duration) HealthCheckOption {
+	return func(c *registeredChecker) {
+		c.timeout = timeout
+	}
+}
+
+// NonCritical a failing non-critical checker only degrades readiness instead of failing it
+func NonCritical() HealthCheckOption {
+	return func(c *registeredChecker) {
+		c.critical = false
+	}
+}
+
+type registeredChecker struct {
+	checker  HealthChecker
+	timeout  time.Duration
+	critical bool
+
+	mu     sync.RWMutex
+	result HealthResult
+}
+
+func (c *registeredChecker) run(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := runWithContext(ctx, c.checker.Check)
+	checkedAt := time.Now()
+	result := HealthResult{
+		Status:    HealthStatusUp,
+		Critical:  c.critical,
+		LatencyMs: float64(checkedAt.Sub(start).Microseconds()) / 1000,
+		CheckedAt: &checkedAt,
+	}
+	if err != nil {
+		result.Status = HealthStatusDown
+		result.Error = err.Error()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil && c.result.Status != HealthStatusDown {
+		logger.GetLogger().Warn(fmt.Sprintf("health check %s failed , error:%s", c.checker.Name(), err.Error()))
+	}
+	c.result = result
+}
+
+func (c *registeredChecker) last() HealthResult {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.result
+}
+
+// HealthResult is the state of a single dependency in the readiness report
+type HealthResult struct {
+	Status    string     `json:"status"`
+	Critical  bool       `json:"critical"`
+	LatencyMs float64    `json:"latency_ms"`
+	Error     string     `json:"error,omitempty"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"` // nil until the first check
+}
+
+// HealthReport is the body returned by the readiness endpoint
+type HealthReport struct {
+	Status string                  `json:"status"`
+	Reason string                  `json:"reason,omitempty"`
+	Checks map[string]HealthResult `json:"checks"`
+}
+
+// RegisterHealthChecker Register a dependency checker for readiness. The names key the
+// report, a name registered twice panics, like a duplicated route
+func (srv *ApiServer) RegisterHealthChecker(checker HealthChecker, opts ...HealthCheckOption) {
+	c := &registeredChecker{
+		checker:  checker,
+		timeout:  healthCheckTimeout,
+		critical: true,
+		result:   HealthResult{Status: HealthStatusUnknown},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.result.Critical = c.critical
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for _, registered := range srv.healthCheckers {
+		if registered.checker.Name() == checker.Name() {
+			panic(fmt.Sprintf("api-server:health checker %s registered twice", checker.Name()))
+		}
+	}
+	srv.healthCheckers = append(srv.healthCheckers, c)
+}
+
+func (srv *ApiServer) getHealthCheckers() []*registeredChecker {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	checkers := make([]*registeredChecker, len(srv.healthCheckers))
+	copy(checkers, srv.healthCheckers)
+	return checkers
+}
+
+// refreshHealthChecks run all registered checks concurrently and cache their results
+func (srv *ApiServer) refreshHealthChecks(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, c := range srv.getHealthCheckers() {
+		wg.Add(1)
+		go func(c *registeredChecker) {
+			defer wg.Done()
+			c.run(ctx)
+		}(c)
+	}
+	wg.Wait()
+}
+
+func (srv *ApiServer) startHealthRefresher() {
+	go func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() {
+			<-srv.getDoneChan()
+			cancel()
+		}()
+
+		ticker := time.NewTicker(healthCheckInterval)
+		defer ticker.Stop()
+		for {
+			srv.refreshHealthChecks(ctx)
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// HealthReport aggregate the cached results, a failing critical checker fails readiness
+// while a failing non-critical one only degrades it
+func (srv *ApiServer) HealthReport() HealthReport {
+	checkers := srv.getHealthCheckers()
+	report := HealthReport{Status: HealthStatusUp, Checks: make(map[string]HealthResult, len(checkers))}
+	for _, c := range checkers {
+		result := c.last()
+		report.Checks[c.checker.Name()] = result
+		if result.Status == HealthStatusUp {
+			continue
+		}
+		if c.critical {
+			report.Status = HealthStatusDown
+		} else if report.Status == HealthStatusUp {
+			report.Status = HealthStatusDegraded
+		}
+	}
+	if srv.isShuttingDown() {
+		report.Status = HealthStatusDown
+		report.Reason = "shutting down"
+	}
+	return report
+}
+
+func (srv *ApiServer) isShuttingDown() bool {
+	return atomic.LoadInt32(&srv.shuttingDown) == 1
+}
+
+// isStopping report Shutdown started, a restart handoff included
+func (srv *ApiServer) isStopping() bool {
+	return atomic.LoadInt32(&srv.stopping) == 1
+}
+
+// runWithContext guard checks which do not honour ctx themselves, such as the mgo ping
+func runWithContext(ctx context.Context, check func(ctx context.Context) error) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (srv *ApiServer) livenessHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealthJson(w, http.StatusOK, map[string]string{"status": HealthStatusUp})
+}
+
+func (srv *ApiServer) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	report := srv.HealthReport()
+	code := http.StatusOK
+	if report.Status == HealthStatusDown {
+		code = http.StatusServiceUnavailable
+	}
+	writeHealthJson(w, code, report)
+}
+
+func writeHealthJson(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// startHealthServer serve liveness on <uri> and <uri>/live, readiness on <uri>/ready,
+// and the admin endpoints when system.admin-token is set
+func (srv *ApiServer) startHealthServer() {
+	if srv.HealthCheckPort <= 0 {
+		if srv.adminHandler() != nil {
+			logger.GetLogger().Warn("api-server:admin endpoints are off, they are served on the health check port")
+		}
+		return
+	}
+	uri := "/" + strings.Trim(srv.HealthCheckURI, "/")
+	if uri == "/" {
+		uri = "/health"
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(uri, srv.livenessHandler)
+	mux.HandleFunc(uri+"/live", srv.livenessHandler)
+	mux.HandleFunc(uri+"/ready", srv.readinessHandler)
+	if admin := srv.adminHandler(); admin != nil {
+		mux.Handle(adminLogLevelURI, admin)
+	}
+
+	srv.HealthServer = &http.Server{
+		Handler:      mux,
+		Addr:         fmt.Sprintf(":%d", srv.HealthCheckPort),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 5 * time.Second,
+	}
+	ln, err := srv.listen("tcp", srv.HealthServer.Addr)
+	if err != nil {
+		logger.GetLogger().Error(fmt.Sprintf("health check server failed , error:%s", err.Error()))
+		return
+	}
+	srv.startHealthRefresher()
+	go func() {
+		logger.GetLogger().Info(fmt.Sprintf("health check run on %s%s", srv.HealthServer.Addr, uri))
+		if err := srv.HealthServer.Serve(ln); err != nil && err != http.ErrServerClosed {
+			logger.GetLogger().Error(fmt.Sprintf("health check server failed , error:%s", err.Error()))
+		}
+	}()
+}
+
+func mysqlHealthCheck(ctx context.Context) error {
+	db := mysql.GetMysqlDB()
+	if db == nil {
+		return errors.New("mysql is not initialized")
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+func redisHealthCheck(ctx context.Context) error {
+	client := redis.GetRedis()
+	if client == nil {
+		return errors.New("redis is not initialized")
+	}
+	return client.Ping(ctx).Err()
+}
+
+func mongoHealthCheck(ctx context.Context) error {
+	session := mongo.GetMongoDB()
+	if session == nil {
+		return errors.New("mongo is not initialized")
+	}
+	s := session.Copy()
+	defer s.Close()
+	return s.Ping()
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthReport(t *testing.T) {
+	initTestLogger(t)
+	srv := &ApiServer{}
+	srv.RegisterHealthChecker(NewHealthChecker("db", func(ctx context.Context) error {
+		return nil
+	}))
+	srv.RegisterHealthChecker(NewHealthChecker("queue", func(ctx context.Context) error {
+		return errors.New("unreachable")
+	}), NonCritical())
+	srv.RegisterHealthChecker(NewHealthChecker("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}), NonCritical(), WithCheckTimeout(10*time.Millisecond))
+
+	{
+		report := srv.HealthReport()
+		assert.Equal(t, HealthStatusDown, report.Status)
+		assert.Equal(t, HealthStatusUnknown, report.Checks["db"].Status)
+		body, _ := json.Marshal(report.Checks["db"])
+		assert.NotContains(t, string(body), "checked_at")
+	}
+	assert.Panics(t, func() {
+		srv.RegisterHealthChecker(NewHealthChecker("db", func(ctx context.Context) error {
+			return nil
+		}))
+	})
+
+	srv.refreshHealthChecks(context.Background())
+	{
+		report := srv.HealthReport()
+		assert.Equal(t, HealthStatusDegraded, report.Status)
+		assert.Equal(t, HealthStatusUp, report.Checks["db"].Status)
+		assert.NotNil(t, report.Checks["db"].CheckedAt)
+		assert.Equal(t, "unreachable", report.Checks["queue"].Error)
+		assert.Equal(t, HealthStatusDown, report.Checks["slow"].Status)
+	}
+
+	srv.shuttingDown = 1
+	{
+		report := srv.HealthReport()
+		assert.Equal(t, HealthStatusDown, report.Status)
+	}
+}
+
+// readiness call the readiness handler and decode its report
+func readiness(t *testing.T, srv *ApiServer) (int, HealthReport) {
+	w := httptest.NewRecorder()
+	srv.readinessHandler(w, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	var report HealthReport
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &report))
+	return w.Code, report
+}
+
+func TestHealthHandlers(t *testing.T) {
+	srv := &ApiServer{}
+	srv.RegisterHealthChecker(NewHealthChecker("db", func(ctx context.Context) error {
+		return nil
+	}))
+	srv.refreshHealthChecks(context.Background())
+
+	w := httptest.NewRecorder()
+	srv.livenessHandler(w, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status": "UP"}`, w.Body.String())
+
+	code, report := readiness(t, srv)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, HealthStatusUp, report.Status)
+	assert.Equal(t, HealthStatusUp, report.Checks["db"].Status)
+
+	// a critical checker not run yet fails readiness, liveness stays up
+	srv.RegisterHealthChecker(NewHealthChecker("queue", func(ctx context.Context) error {
+		return nil
+	}))
+	code, report = readiness(t, srv)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, HealthStatusDown, report.Status)
+	assert.Equal(t, HealthStatusUnknown, report.Checks["queue"].Status)
+	w = httptest.NewRecorder()
+	srv.livenessHandler(w, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,291 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chenxuan520/goweb-platform/logger"
+)
+
+const (
+	defaultHookTimeout = 15 * time.Second
+	// ClientHookPriority is the priority of the mysql, redis and mongo hooks of WithMysql,
+	// WithRedis and WithMongo: they start before and stop after the default priority 0
+	ClientHookPriority = -100
+)
+
+// Hook is a named step of the server lifecycle, OnStart runs before the server listens and
+// OnStop after it is drained. Either of them may be nil
+type Hook struct {
+	Name string
+	// Priority order the start, lower first, hooks of the same stage run in parallel
+	Priority int
+	// DependsOn name the hooks which must be started before this one
+	DependsOn []string
+	// Timeout bound each of OnStart and OnStop, default 15s
+	Timeout time.Duration
+	OnStart func(ctx context.Context) error
+	OnStop  func(ctx context.Context) error
+}
+
+// HookResult is the outcome of one hook run, as logged in the lifecycle summary
+type HookResult struct {
+	Name     string
+	Phase    string
+	Duration time.Duration
+	Err      error
+}
+
+// Lifecycle start hooks stage by stage and stop the started ones in reverse order
+type Lifecycle struct {
+	mu      sync.Mutex
+	hooks   []Hook
+	started [][]Hook
+}
+
+// Append add hooks, those appended after Start are ignored until the next Start
+func (l *Lifecycle) Append(hooks ...Hook) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks = append(l.hooks, hooks...)
+}
+
+// stages group the hooks by the stage they start in, a hook starts in the stage of its
+// Priority or after the stages of its dependencies, whichever is later
+func stages(hooks []Hook) ([][]Hook, error) {
+	byName := make(map[string]*Hook, len(hooks))
+	for i := range hooks {
+		if _, ok := byName[hooks[i].Name]; ok {
+			return nil, fmt.Errorf("hook %s registered twice", hooks[i].Name)
+		}
+		byName[hooks[i].Name] = &hooks[i]
+	}
+
+	ranks := make(map[string]int, len(hooks))
+	visiting := make(map[string]bool)
+	var rank func(h *Hook) (int, error)
+	rank = func(h *Hook) (int, error) {
+		if r, ok := ranks[h.Name]; ok {
+			return r, nil
+		}
+		if visiting[h.Name] {
+			return 0, fmt.Errorf("hook %s has a dependency cycle", h.Name)
+		}
+		visiting[h.Name] = true
+		defer delete(visiting, h.Name)
+		r := h.Priority
+		for _, name := range h.DependsOn {
+			dep, ok := byName[name]
+			if !ok {
+				return 0, fmt.Errorf("hook %s depends on unknown hook %s", h.Name, name)
+			}
+			depRank, err := rank(dep)
+			if err != nil {
+				return 0, err
+			}
+			if depRank >= r {
+				r = depRank + 1
+			}
+		}
+		ranks[h.Name] = r
+		return r, nil
+	}
+
+	grouped := make(map[int][]Hook)
+	for i := range hooks {
+		r, err := rank(&hooks[i])
+		if err != nil {
+			return nil, err
+		}
+		grouped[r] = append(grouped[r], hooks[i])
+	}
+	keys := make([]int, 0, len(grouped))
+	for r := range grouped {
+		keys = append(keys, r)
+	}
+	sort.Ints(keys)
+	result := make([][]Hook, 0, len(keys))
+	for _, r := range keys {
+		result = append(result, grouped[r])
+	}
+	return result, nil
+}
+
+// Start run OnStart stage by stage, when a stage fails the hooks already started are
+// stopped in reverse order and the errors are returned
+func (l *Lifecycle) Start(ctx context.Context) ([]HookResult, error) {
+	l.mu.Lock()
+	hooks := append([]Hook(nil), l.hooks...)
+	l.mu.Unlock()
+	groups, err := stages(hooks)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []HookResult
+	for _, group := range groups {
+		stageResults := runStage(ctx, "start", group, func(h Hook) func(ctx context.Context) error {
+			return h.OnStart
+		})
+		results = append(results, stageResults...)
+		// of a failing stage only the hooks which did start are stopped, those without
+		// OnStart included
+		failed := make(map[string]bool)
+		for _, r := range stageResults {
+			if r.Err != nil {
+				failed[r.Name] = true
+			}
+		}
+		succeeded := make([]Hook, 0, len(group))
+		for _, h := range group {
+			if !failed[h.Name] {
+				succeeded = append(succeeded, h)
+			}
+		}
+		l.mu.Lock()
+		l.started = append(l.started, succeeded)
+		l.mu.Unlock()
+		if err := joinErrors(stageResults); err != nil {
+			stopResults, _ := l.Stop(ctx)
+			return append(results, stopResults...), err
+		}
+	}
+	return results, nil
+}
+
+// Stop run OnStop of the started stages, the last started first
+func (l *Lifecycle) Stop(ctx context.Context) ([]HookResult, error) {
+	l.mu.Lock()
+	started := l.started
+	l.started = nil
+	l.mu.Unlock()
+
+	var results []HookResult
+	for i := len(started) - 1; i >= 0; i-- {
+		results = append(results, runStage(ctx, "stop", started[i], func(h Hook) func(ctx context.Context) error {
+			return h.OnStop
+		})...)
+	}
+	return results, joinErrors(results)
+}
+
+// runStage run the phase of every hook of a stage in parallel, each bounded by its timeout.
+// A hook without a callback for the phase is skipped and has no result
+func runStage(ctx context.Context, phase string, group []Hook, fn func(h Hook) func(ctx context.Context) error) []HookResult {
+	var (
+		hooks []Hook
+		runs  []func(ctx context.Context) error
+	)
+	for _, h := range group {
+		if run := fn(h); run != nil {
+			hooks = append(hooks, h)
+			runs = append(runs, run)
+		}
+	}
+	results := make([]HookResult, len(hooks))
+	var wg sync.WaitGroup
+	for i, h := range hooks {
+		run := runs[i]
+		wg.Add(1)
+		go func(i int, h Hook) {
+			defer wg.Done()
+			timeout := h.Timeout
+			if timeout <= 0 {
+				timeout = defaultHookTimeout
+			}
+			hookCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			start := time.Now()
+			err := runWithContext(hookCtx, func(ctx context.Context) (err error) {
+				defer func() {
+					if r := recover(); r != nil {
+						err = fmt.Errorf("panic: %v", r)
+					}
+				}()
+				return run(ctx)
+			})
+			results[i] = HookResult{Name: h.Name, Phase: phase, Duration: time.Since(start), Err: err}
+		}(i, h)
+	}
+	wg.Wait()
+	return results
+}
+
+func joinErrors(results []HookResult) error {
+	var msgs []string
+	for _, r := range results {
+		if r.Err != nil {
+			msgs = append(msgs, fmt.Sprintf("%s %s: %s", r.Phase, r.Name, r.Err.Error()))
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+// logHookResults log a line per hook then a summary line of the phase
+func logHookResults(phase string, results []HookResult, elapsed time.Duration) {
+	if len(results) == 0 {
+		return
+	}
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			logger.GetLogger().Error(fmt.Sprintf("lifecycle %s %s failed in %s , error:%s", r.Phase, r.Name, r.Duration, r.Err.Error()))
+			continue
+		}
+		logger.GetLogger().Info(fmt.Sprintf("lifecycle %s %s done in %s", r.Phase, r.Name, r.Duration))
+	}
+	logger.GetLogger().Info(fmt.Sprintf("lifecycle %s summary: %d hooks, %d failed, %s", phase, len(results), failed, elapsed))
+}
+
+// RegisterHook Register lifecycle hooks run around ListenAndServe and Shutdown
+func (srv *ApiServer) RegisterHook(hooks ...Hook) {
+	srv.Lifecycle.Append(hooks...)
+}
+
+// OnStart Register a start hook with the default priority
+func (srv *ApiServer) OnStart(name string, fn func(ctx context.Context) error) {
+	srv.RegisterHook(Hook{Name: name, OnStart: fn})
+}
+
+// OnStop Register a stop hook with the default priority
+func (srv *ApiServer) OnStop(name string, fn func(ctx context.Context) error) {
+	srv.RegisterHook(Hook{Name: name, OnStop: fn})
+}
+
+func (srv *ApiServer) startHooks(ctx context.Context) error {
+	start := time.Now()
+	results, err := srv.Lifecycle.Start(ctx)
+	logHookResults("start", results, time.Since(start))
+	return err
+}
+
+func (srv *ApiServer) stopHooks(ctx context.Context) {
+	start := time.Now()
+	results, _ := srv.Lifecycle.Stop(ctx)
+	logHookResults("stop", results, time.Since(start))
+}
+
+// shutdownWait is how long readiness fails before the servers drain, System.ShutdownWait or 1s
+func (srv *ApiServer) shutdownWait() time.Duration {
+	if srv.Config != nil && srv.Config.System.ShutdownWait != 0 {
+		return orDefault(srv.Config.System.ShutdownWait, 0)
+	}
+	return defaultShutdownWait
+}
+
+// shutdownTimeout bound the whole Shutdown, System.ShutdownTimeout or 15s
+func (srv *ApiServer) shutdownTimeout() time.Duration {
+	if srv.Config != nil && srv.Config.System.ShutdownTimeout > 0 {
+		return srv.Config.System.ShutdownTimeout
+	}
+	return defaultShutdownTimeout
+}
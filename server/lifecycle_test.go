@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	platform "github.com/chenxuan520/goweb-platform"
+	"github.com/chenxuan520/goweb-platform/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLifecycle(t *testing.T) {
+	initTestLogger(t)
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	record := func(event string) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, event)
+			return nil
+		}
+	}
+
+	var l Lifecycle
+	l.Append(
+		Hook{Name: "cache", DependsOn: []string{"db"}, OnStart: record("start cache"), OnStop: record("stop cache")},
+		Hook{Name: "db", Priority: -1, OnStart: record("start db"), OnStop: record("stop db")},
+		Hook{Name: "queue", Priority: -1, OnStart: record("start queue"), OnStop: record("stop queue")},
+		Hook{Name: "flush", Priority: -1, OnStop: record("stop flush")},
+	)
+	// a hook without OnStart has no start result, it is stopped all the same
+	results, err := l.Start(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, results, 3)
+	assert.ElementsMatch(t, []string{"start db", "start queue"}, order[:2])
+	assert.Equal(t, "start cache", order[2])
+
+	order = nil
+	results, err = l.Stop(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, results, 4)
+	assert.Equal(t, "stop cache", order[0])
+	assert.ElementsMatch(t, []string{"stop db", "stop queue", "stop flush"}, order[1:])
+
+	// a failing stage stop the hooks started before it, not the failed one
+	order = nil
+	l = Lifecycle{}
+	l.Append(
+		Hook{Name: "db", OnStart: record("start db"), OnStop: record("stop db")},
+		Hook{Name: "slow", Priority: 1, Timeout: 10 * time.Millisecond, OnStart: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}, OnStop: record("stop slow")},
+		Hook{Name: "broken", Priority: 1, OnStart: func(ctx context.Context) error {
+			return errors.New("boom")
+		}},
+	)
+	results, err = l.Start(context.Background())
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "start broken: boom")
+	assert.Contains(t, err.Error(), "start slow: context deadline exceeded")
+	assert.Equal(t, []string{"start db", "stop db"}, order)
+	// broken has no OnStop, nothing is reported for it on the way back
+	assert.Len(t, results, 4)
+
+	l = Lifecycle{}
+	l.Append(Hook{Name: "a", DependsOn: []string{"b"}}, Hook{Name: "b", DependsOn: []string{"a"}})
+	_, err = l.Start(context.Background())
+	assert.NotNil(t, err)
+}
+
+func TestClientHookStopsLast(t *testing.T) {
+	initTestLogger(t)
+	mr := miniredis.RunT(t)
+	srv, err := New(&platform.Config{Redis: platform.Redis{Addr: mr.Addr()}}, WithRedis())
+	assert.Nil(t, err)
+
+	var flushErr error
+	srv.OnStop("flush", func(ctx context.Context) error {
+		// the application hooks stop while the clients are still open
+		flushErr = redis.GetRedis().Set(ctx, "flushed", "1", 0).Err()
+		return flushErr
+	})
+	_, err = srv.Lifecycle.Start(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, srv.subscriptions, 1)
+	results, err := srv.Lifecycle.Stop(context.Background())
+	assert.Nil(t, err)
+	assert.Nil(t, flushErr)
+	if assert.Len(t, results, 3) {
+		assert.ElementsMatch(t, []string{"config-subscriptions", "flush"}, []string{results[0].Name, results[1].Name})
+		assert.Equal(t, "redis", results[2].Name)
+	}
+	// the reloads of a stopped server are over
+	assert.Empty(t, srv.subscriptions)
+	flushed, _ := mr.Get("flushed")
+	assert.Equal(t, "1", flushed)
+}
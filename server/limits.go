@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	platform "github.com/chenxuan520/goweb-platform"
+	"github.com/chenxuan520/goweb-platform/response"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultReadTimeout    = 20 * time.Second
+	defaultWriteTimeout   = 20 * time.Second
+	defaultMaxHeaderBytes = 1 << 20
+)
+
+type connKey struct{}
+
+// orDefault return def for a zero d, and no limit for a negative one
+func orDefault(d, def time.Duration) time.Duration {
+	switch {
+	case d == 0:
+		return def
+	case d < 0:
+		return 0
+	}
+	return d
+}
+
+// NewHttpServer build a server of handler on addr with the timeouts and limits of System
+func (srv *ApiServer) NewHttpServer(handler http.Handler, addr string) *http.Server {
+	var sys platform.System
+	if srv.Config != nil {
+		sys = srv.Config.System
+	}
+	maxHeaderBytes := sys.MaxHeaderBytes
+	if maxHeaderBytes == 0 {
+		maxHeaderBytes = defaultMaxHeaderBytes
+	}
+	server := &http.Server{
+		Handler:           handler,
+		Addr:              addr,
+		ReadTimeout:       orDefault(sys.ReadTimeout, defaultReadTimeout),
+		ReadHeaderTimeout: orDefault(sys.ReadHeaderTimeout, 0),
+		WriteTimeout:      orDefault(sys.WriteTimeout, defaultWriteTimeout),
+		IdleTimeout:       orDefault(sys.IdleTimeout, 0),
+		MaxHeaderBytes:    maxHeaderBytes,
+		// keep the connection reachable for WithWriteTimeout
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, connKey{}, c)
+		},
+	}
+	server.SetKeepAlivesEnabled(!sys.DisableKeepAlive)
+	return server
+}
+
+// WithWriteTimeout replace the server write timeout for the routes of a group, such as
+// downloads or SSE, 0 removes it. HTTP/2 streams share their connection, so they keep
+// the server timeout
+func WithWriteTimeout(timeout time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conn, ok := c.Request.Context().Value(connKey{}).(net.Conn)
+		if ok && c.Request.ProtoMajor == 1 {
+			var deadline time.Time
+			if timeout > 0 {
+				deadline = time.Now().Add(timeout)
+			}
+			_ = conn.SetWriteDeadline(deadline)
+		}
+		c.Next()
+	}
+}
+
+// maxBodySize answer 413 to a request declaring a body over limit, and cut a body
+// growing over it while read
+func maxBodySize(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > limit {
+			response.Fail(c, response.ErrPayloadTooLarge)
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
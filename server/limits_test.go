@@ -0,0 +1,67 @@
+package server
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	platform "github.com/chenxuan520/goweb-platform"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	srv := &ApiServer{Config: &platform.Config{System: platform.System{
+		WriteTimeout: 50 * time.Millisecond,
+		ReadTimeout:  -1,
+		MaxBodySize:  8,
+	}}}
+	engine := gin.New()
+	engine.Use(maxBodySize(srv.Config.System.MaxBodySize))
+	slow := func(c *gin.Context) {
+		time.Sleep(150 * time.Millisecond)
+		c.String(http.StatusOK, "done")
+	}
+	engine.GET("/slow", slow)
+	engine.Group("/stream", WithWriteTimeout(0)).GET("", slow)
+	engine.POST("/upload", func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.Status(http.StatusRequestEntityTooLarge)
+			return
+		}
+		c.String(http.StatusOK, string(body))
+	})
+
+	server := srv.NewHttpServer(engine, "")
+	assert.Equal(t, time.Duration(0), server.ReadTimeout)
+	assert.Equal(t, defaultMaxHeaderBytes, server.MaxHeaderBytes)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go server.Serve(ln)
+	defer server.Close()
+	url := "http://" + ln.Addr().String()
+
+	// the write deadline of the server cut the slow response
+	_, err = http.Get(url + "/slow")
+	assert.NotNil(t, err)
+	resp, err := http.Get(url + "/stream")
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	resp, err = http.Post(url+"/upload", "text/plain", strings.NewReader("0123456789"))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.Contains(t, string(body), `"code":41300`)
+	resp.Body.Close()
+	resp, err = http.Post(url+"/upload", "text/plain", strings.NewReader("0123"))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+}
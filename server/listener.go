@@ -0,0 +1,215 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	platform "github.com/chenxuan520/goweb-platform"
+	"github.com/chenxuan520/goweb-platform/logger"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// certCheckInterval throttle the stat of the cert files done on handshakes
+const certCheckInterval = 10 * time.Second
+
+// startListeners serve the engine on every configured listener besides srv.Addr,
+// each one is shut down with the main server
+func (srv *ApiServer) startListeners(handler http.Handler) error {
+	if srv.Config == nil {
+		return nil
+	}
+	for _, conf := range srv.Config.Listeners {
+		server, ln, err := srv.newListenerServer(conf, handler)
+		if err != nil {
+			return fmt.Errorf("listener %s: %w", conf.Addr, err)
+		}
+		srv.mu.Lock()
+		srv.listenerServers = append(srv.listenerServers, server)
+		srv.mu.Unlock()
+
+		go func(conf platform.Listener) {
+			var err error
+			logger.GetLogger().Info(fmt.Sprintf("api-server listener run on %s %s", listenerNetwork(conf), conf.Addr))
+			if server.TLSConfig != nil {
+				err = server.ServeTLS(ln, "", "")
+			} else {
+				err = server.Serve(ln)
+			}
+			if err != nil && err != http.ErrServerClosed {
+				logger.GetLogger().Error(fmt.Sprintf("api-server listener %s failed , error:%s", conf.Addr, err.Error()))
+			}
+		}(conf)
+	}
+	return nil
+}
+
+func (srv *ApiServer) newListenerServer(conf platform.Listener, handler http.Handler) (*http.Server, net.Listener, error) {
+	server := srv.NewHttpServer(handler, conf.Addr)
+	if conf.H2C {
+		server.Handler = h2c.NewHandler(handler, &http2.Server{})
+	}
+	if conf.CertFile != "" {
+		tlsConfig, err := newTLSConfig(conf)
+		if err != nil {
+			return nil, nil, err
+		}
+		server.TLSConfig = tlsConfig
+	}
+
+	network := listenerNetwork(conf)
+	if network == "unix" {
+		if err := removeStaleSocket(conf.Addr); err != nil {
+			return nil, nil, err
+		}
+	}
+	ln, err := srv.listen(network, conf.Addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if network == "unix" {
+		// the socket outlives Shutdown, a hot restarted child keeps serving on it
+		if unixLn, ok := ln.(*net.UnixListener); ok {
+			unixLn.SetUnlinkOnClose(false)
+		}
+		if conf.SocketMode != "" {
+			mode, err := strconv.ParseUint(conf.SocketMode, 8, 32)
+			if err != nil {
+				return nil, nil, fmt.Errorf("socket-mode %s is not octal", conf.SocketMode)
+			}
+			if err := os.Chmod(conf.Addr, os.FileMode(mode)); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return server, ln, nil
+}
+
+func listenerNetwork(conf platform.Listener) string {
+	if conf.Network == "" {
+		return "tcp"
+	}
+	return conf.Network
+}
+
+// removeStaleSocket remove the socket file left by a previous run, refusing to remove
+// anything else or a socket still served by another process
+func removeStaleSocket(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	if inheritedExists(path) {
+		return nil
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is served by another process", path)
+	}
+	return os.Remove(path)
+}
+
+// newTLSConfig serve the cert of conf, reloaded once rotated, and verify the client
+// certs against the client CA when set
+func newTLSConfig(conf platform.Listener) (*tls.Config, error) {
+	reloader := &certReloader{certFile: conf.CertFile, keyFile: conf.KeyFile}
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.getCertificate,
+	}
+	if conf.ClientCAFile != "" {
+		pem, err := os.ReadFile(conf.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", conf.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// certReloader reload the key pair when the cert or key file changes, so a rotated cert
+// is served without a restart
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+func (r *certReloader) modified() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (r *certReloader) load() error {
+	modTime, err := r.modified()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert, r.modTime, r.lastCheck = &cert, modTime, time.Now()
+	return nil
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	if time.Since(r.lastCheck) < certCheckInterval {
+		defer r.mu.Unlock()
+		return r.cert, nil
+	}
+	r.lastCheck = time.Now()
+	current, previous := r.cert, r.modTime
+	r.mu.Unlock()
+
+	if modTime, err := r.modified(); err == nil && modTime.After(previous) {
+		// keep serving the old cert while a rotation is half written
+		if err := r.load(); err != nil {
+			logger.GetLogger().Warn(fmt.Sprintf("api-server:reload cert %s failed , error:%s", r.certFile, err.Error()))
+			return current, nil
+		}
+		logger.GetLogger().Info(fmt.Sprintf("api-server:reload rotated cert %s", r.certFile))
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.cert, nil
+	}
+	return current, nil
+}
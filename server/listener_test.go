@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	platform "github.com/chenxuan520/goweb-platform"
+	"github.com/chenxuan520/goweb-platform/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+)
+
+// writeCert write a cert for 127.0.0.1 and 127.0.0.2 signed by parent, self signed when parent is nil
+// initTestLogger init the global logger at the error level in a temp dir, the previous
+// logger and level are restored when the test ends
+func initTestLogger(t *testing.T) {
+	old, level := logger.GetLogger(), logger.GetLevel()
+	logger.Init("error", "console", "", t.TempDir(), false, "", "", false)
+	t.Cleanup(func() {
+		logger.SetLogger(old)
+		_ = logger.SetLevel(level)
+	})
+}
+
+func writeCert(t *testing.T, dir, name string, parent *tls.Certificate) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("127.0.0.2")},
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+	signer, signerKey := template, interface{}(key)
+	if parent != nil {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	assert.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, name+".crt"), certPem, 0600))
+	assert.Nil(t, os.WriteFile(filepath.Join(dir, name+".key"), keyPem, 0600))
+	cert, err := tls.X509KeyPair(certPem, keyPem)
+	assert.Nil(t, err)
+	cert.Leaf, _ = x509.ParseCertificate(der)
+	return cert
+}
+
+func TestListeners(t *testing.T) {
+	initTestLogger(t)
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	ca := writeCert(t, dir, "ca", nil)
+	writeCert(t, dir, "server", &ca)
+	client := writeCert(t, dir, "client", &ca)
+	socket := filepath.Join(dir, "api.sock")
+
+	srv := &ApiServer{
+		Config: &platform.Config{Listeners: []platform.Listener{
+			{Addr: "127.0.0.1:0", CertFile: filepath.Join(dir, "server.crt"), KeyFile: filepath.Join(dir, "server.key")},
+			{Addr: "127.0.0.2:0", CertFile: filepath.Join(dir, "server.crt"), KeyFile: filepath.Join(dir, "server.key"), ClientCAFile: filepath.Join(dir, "ca.crt")},
+			{Addr: "127.0.0.3:0", H2C: true},
+			{Network: "unix", Addr: socket, SocketMode: "0600"},
+		}},
+	}
+	engine := gin.New()
+	engine.GET("/proto", func(c *gin.Context) {
+		c.String(http.StatusOK, c.Request.Proto)
+	})
+	assert.Nil(t, srv.startListeners(engine))
+	defer func() {
+		for _, server := range srv.listenerServers {
+			server.Shutdown(context.Background())
+		}
+	}()
+	addr := func(key string) string {
+		return srv.listeners[key].Addr().String()
+	}
+	get := func(client *http.Client, url string) (string, error) {
+		resp, err := client.Get(url)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+	https := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}, ForceAttemptHTTP2: true}}
+	proto, err := get(https, "https://"+addr("127.0.0.1:0")+"/proto")
+	assert.Nil(t, err)
+	assert.Equal(t, "HTTP/2.0", proto)
+
+	// mutual TLS refuse a client without cert
+	_, err = get(https, "https://"+addr("127.0.0.2:0")+"/proto")
+	assert.NotNil(t, err)
+	mtls := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{client}}}}
+	_, err = get(mtls, "https://"+addr("127.0.0.2:0")+"/proto")
+	assert.Nil(t, err)
+
+	h2cClient := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	proto, err = get(h2cClient, "http://"+addr("127.0.0.3:0")+"/proto")
+	assert.Nil(t, err)
+	assert.Equal(t, "HTTP/2.0", proto)
+
+	unixClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		},
+	}}
+	proto, err = get(unixClient, "http://unix/proto")
+	assert.Nil(t, err)
+	assert.Equal(t, "HTTP/1.1", proto)
+	info, err := os.Stat(socket)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestCertReloader(t *testing.T) {
+	initTestLogger(t)
+	dir := t.TempDir()
+	first := writeCert(t, dir, "server", nil)
+	reloader := &certReloader{certFile: filepath.Join(dir, "server.crt"), keyFile: filepath.Join(dir, "server.key")}
+	assert.Nil(t, reloader.load())
+
+	second := writeCert(t, dir, "server", nil)
+	future := time.Now().Add(time.Minute)
+	assert.Nil(t, os.Chtimes(reloader.certFile, future, future))
+	// within the check interval the cached cert is served
+	cert, err := reloader.getCertificate(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, first.Certificate[0], cert.Certificate[0])
+
+	reloader.lastCheck = time.Time{}
+	cert, err = reloader.getCertificate(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, second.Certificate[0], cert.Certificate[0])
+}
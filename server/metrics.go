@@ -0,0 +1,37 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/chenxuan520/goweb-platform/logger"
+	"github.com/chenxuan520/goweb-platform/metrics"
+)
+
+// startMetricsServer serve the prometheus metrics on <MetricsPort>/metrics
+func (srv *ApiServer) startMetricsServer() {
+	if srv.MetricsPort <= 0 {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+
+	srv.MetricsServer = &http.Server{
+		Handler:      mux,
+		Addr:         fmt.Sprintf(":%d", srv.MetricsPort),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 20 * time.Second,
+	}
+	ln, err := srv.listen("tcp", srv.MetricsServer.Addr)
+	if err != nil {
+		logger.GetLogger().Error(fmt.Sprintf("metrics server failed , error:%s", err.Error()))
+		return
+	}
+	go func() {
+		logger.GetLogger().Info(fmt.Sprintf("metrics run on %s/metrics", srv.MetricsServer.Addr))
+		if err := srv.MetricsServer.Serve(ln); err != nil && err != http.ErrServerClosed {
+			logger.GetLogger().Error(fmt.Sprintf("metrics server failed , error:%s", err.Error()))
+		}
+	}()
+}
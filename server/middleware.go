@@ -5,18 +5,33 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net"
-	"net/http"
 	"net/http/httputil"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/chenxuan520/goweb-platform/logger"
+	"github.com/chenxuan520/goweb-platform/response"
+	"github.com/chenxuan520/goweb-platform/utils"
 	"github.com/gin-gonic/gin"
-	"gitlab.dian.org.cn/helper/miniapp-platform/logger"
+	"go.uber.org/zap"
 )
 
-// ApiRecovery recovery any panics and writes a 500 if there was one.
+const (
+	// RequestIDKey is the gin.Context key of the request id
+	RequestIDKey = "request_id"
+	// UserIDKey is the gin.Context key of the authenticated user id
+	UserIDKey = "user_id"
+
+	headerUserID = "X-User-Id"
+)
+
+// maxRequestIDLength reject oversized incoming ids instead of echoing them back
+const maxRequestIDLength = 128
+
+// ApiRecovery recovery any panics and writes a 500 if there was one, in the response envelope.
 func (srv *ApiServer) apiRecoveryMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		defer func() {
@@ -40,17 +55,18 @@ func (srv *ApiServer) apiRecoveryMiddleware() gin.HandlerFunc {
 					}
 				}
 
+				log := logger.FromContext(c.Request.Context())
 				if brokenPipe {
-					logger.GetLogger().Error(fmt.Sprintf("%s\n%s%s", err, string(httpRequest), reset))
+					log.Error(fmt.Sprintf("%s\n%s%s", err, string(httpRequest), reset))
 				} else {
-					logger.GetLogger().Error(fmt.Sprintf("[Recovery] %s panic recovered:\n%s\n%s%s",
+					log.Error(fmt.Sprintf("[Recovery] %s panic recovered:\n%s\n%s%s",
 						formatTime(time.Now()), err, stack, reset))
 				}
 				if brokenPipe {
 					c.Error(err.(error))
 					c.Abort()
 				} else {
-					c.AbortWithStatus(http.StatusInternalServerError)
+					response.Fail(c, response.ErrInternal)
 				}
 			}
 		}()
@@ -58,24 +74,70 @@ func (srv *ApiServer) apiRecoveryMiddleware() gin.HandlerFunc {
 	}
 }
 
-//跨域
-func (srv *ApiServer) cors() gin.HandlerFunc {
+// requestID reuse the incoming X-Request-ID or generate one, and expose it in the response,
+// the gin.Context and the request context.Context
+func (srv *ApiServer) requestID() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		method := c.Request.Method
-		origin := c.Request.Header.Get("Origin")
-		c.Header("Access-Control-Allow-Origin", origin)
-		c.Header("Access-Control-Allow-Headers", "Content-Type,AccessToken,X-CSRF-Token, Authorization, Token,Authorization,X-User-Id")
-		c.Header("Access-Control-Allow-Methods", "POST, GET, OPTIONS,DELETE,PUT")
-		c.Header("Access-Control-Expose-Headers", "Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers, Content-Type")
-		c.Header("Access-Control-Allow-Credentials", "true")
-
-		if method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
+		requestID := c.GetHeader(utils.HeaderRequestID)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
 		}
+		c.Set(RequestIDKey, requestID)
+		c.Request = c.Request.WithContext(utils.WithRequestID(c.Request.Context(), requestID))
+		c.Header(utils.HeaderRequestID, requestID)
 		c.Next()
 	}
 }
 
+// GetRequestID return the request id of the current request
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(RequestIDKey)
+}
+
+// requestLogger seed the request scoped logger, see logger.FromContext
+func (srv *ApiServer) requestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		fields := []zap.Field{
+			zap.String("method", c.Request.Method),
+			zap.String("route", c.FullPath()),
+			zap.String("client_ip", c.ClientIP()),
+		}
+		// the header is whatever the client claims, only SetUserID sets the authenticated user
+		if claimed := c.GetHeader(headerUserID); claimed != "" {
+			fields = append(fields, zap.String("claimed_user_id", claimed))
+		}
+		c.Request = c.Request.WithContext(logger.WithContext(c.Request.Context(), fields...))
+		c.Next()
+	}
+}
+
+// SetUserID record the authenticated user, logs written afterwards in the request carry it.
+// Call it once per request, the auth middleware being the only caller
+func SetUserID(c *gin.Context, userID string) {
+	c.Set(UserIDKey, userID)
+	c.Request = c.Request.WithContext(logger.WithContext(c.Request.Context(), zap.String("user_id", userID)))
+}
+
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range requestID {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	requestID, err := utils.UUID()
+	if err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return requestID
+}
+
 var (
 	dunno     = []byte("???")
 	centerDot = []byte("·")
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chenxuan520/goweb-platform/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestLoggerUserID(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	old := logger.SetLogger(zap.New(core))
+	t.Cleanup(func() {
+		logger.SetLogger(old)
+	})
+
+	srv := &ApiServer{}
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(srv.requestID(), srv.requestLogger())
+	engine.GET("/", func(c *gin.Context) {
+		// a client header is no authenticated user
+		assert.Empty(t, c.GetString(UserIDKey))
+		SetUserID(c, "42")
+		logger.FromContext(c.Request.Context()).Info("done")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(headerUserID, "1")
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logs.FilterMessage("done").All()
+	if assert.Len(t, entries, 1) {
+		fields := entries[0].ContextMap()
+		assert.Equal(t, "1", fields["claimed_user_id"])
+		assert.Equal(t, "42", fields["user_id"])
+		userIDs := 0
+		for _, f := range entries[0].Context {
+			if f.Key == "user_id" {
+				userIDs++
+			}
+		}
+		assert.Equal(t, 1, userIDs)
+	}
+}
@@ -0,0 +1,18 @@
+package server
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// pprofHandler serve the net/http/pprof endpoints on a mux of their own, so they are never
+// exposed through http.DefaultServeMux
+func pprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
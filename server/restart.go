@@ -0,0 +1,222 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/chenxuan520/goweb-platform/logger"
+)
+
+const (
+	// envListeners list the addresses of the inherited sockets, the first one is fd 3
+	envListeners = "GOWEB_LISTENERS"
+	// envReadyFd is the pipe the child writes to once it serves
+	envReadyFd = "GOWEB_READY_FD"
+
+	restartReadyTimeout = 30 * time.Second
+)
+
+var (
+	inheritOnce sync.Once
+	inheritMu   sync.Mutex
+	inherited   map[string]*os.File
+	readyOnce   sync.Once
+)
+
+// parseInherited read the sockets passed by the parent of a hot restart
+func parseInherited() {
+	inherited = make(map[string]*os.File)
+	value, ok := os.LookupEnv(envListeners)
+	if !ok {
+		return
+	}
+	_ = os.Unsetenv(envListeners)
+	for i, addr := range strings.Split(value, ",") {
+		if addr == "" {
+			continue
+		}
+		inherited[addr] = os.NewFile(uintptr(3+i), addr)
+	}
+}
+
+// takeInherited return the socket inherited for addr, nil when there is none
+func takeInherited(addr string) *os.File {
+	inheritOnce.Do(parseInherited)
+	inheritMu.Lock()
+	defer inheritMu.Unlock()
+	f := inherited[addr]
+	delete(inherited, addr)
+	return f
+}
+
+// inheritedExists tell whether a hot restart passed a socket for addr
+func inheritedExists(addr string) bool {
+	inheritOnce.Do(parseInherited)
+	inheritMu.Lock()
+	defer inheritMu.Unlock()
+	_, ok := inherited[addr]
+	return ok
+}
+
+// listen reuse the socket of addr inherited from a hot restart, else bind a new one,
+// and remember it so the next restart hands it over
+func (srv *ApiServer) listen(network, addr string) (net.Listener, error) {
+	var (
+		ln  net.Listener
+		err error
+	)
+	if f := takeInherited(addr); f != nil {
+		ln, err = net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("inherit listener %s: %w", addr, err)
+		}
+	} else if ln, err = net.Listen(network, addr); err != nil {
+		return nil, err
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.listeners == nil {
+		srv.listeners = make(map[string]net.Listener)
+	}
+	srv.listeners[addr] = ln
+	return ln, nil
+}
+
+// notifyReady tell the parent of a hot restart that this process serves, the parent then
+// drains. The inherited sockets nobody asked for are closed
+func (srv *ApiServer) notifyReady() {
+	readyOnce.Do(func() {
+		inheritOnce.Do(parseInherited)
+		inheritMu.Lock()
+		for addr, f := range inherited {
+			f.Close()
+			delete(inherited, addr)
+		}
+		inheritMu.Unlock()
+
+		value, ok := os.LookupEnv(envReadyFd)
+		if !ok {
+			return
+		}
+		_ = os.Unsetenv(envReadyFd)
+		fd, err := strconv.Atoi(value)
+		if err != nil {
+			return
+		}
+		pipe := os.NewFile(uintptr(fd), "ready")
+		defer pipe.Close()
+		if _, err := pipe.Write([]byte{1}); err != nil {
+			logger.GetLogger().Error(fmt.Sprintf("api-server:notify restart ready failed , error:%s", err.Error()))
+		}
+	})
+}
+
+// Restart start the binary again with the listening sockets, once the child serves this
+// process drains through Shutdown, like tableflip or endless. Readiness stays up meanwhile
+// as the child answers on the shared listeners. A child failing to get ready is killed and
+// this process keeps serving
+func (srv *ApiServer) Restart() error {
+	if !atomic.CompareAndSwapInt32(&srv.restarting, 0, 1) {
+		return errors.New("restart already in progress")
+	}
+	defer atomic.StoreInt32(&srv.restarting, 0)
+	if srv.isStopping() {
+		return errors.New("server is shutting down")
+	}
+
+	addrs, files, err := srv.listenerFiles()
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	if err != nil {
+		return err
+	}
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyR.Close()
+
+	env := make([]string, 0, len(os.Environ())+2)
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, envListeners+"=") && !strings.HasPrefix(kv, envReadyFd+"=") {
+			env = append(env, kv)
+		}
+	}
+	env = append(env, envListeners+"="+strings.Join(addrs, ","), fmt.Sprintf("%s=%d", envReadyFd, 3+len(files)))
+	process, err := startChild(env, append(files, readyW))
+	// only the child holds the write end now, so its exit ends the read below
+	readyW.Close()
+	if err != nil {
+		return fmt.Errorf("start child: %w", err)
+	}
+
+	if err := waitReady(readyR, restartReadyTimeout); err != nil {
+		_ = process.Kill()
+		_, _ = process.Wait()
+		return fmt.Errorf("child %d not ready: %w", process.Pid, err)
+	}
+	logger.GetLogger().Info(fmt.Sprintf("api-server:child %d is ready, drain the old process", process.Pid))
+	ctx, cancel := context.WithTimeout(context.Background(), srv.shutdownTimeout())
+	defer cancel()
+	srv.shutdown(ctx, true)
+	return nil
+}
+
+// listenerFiles dup the listening sockets, sorted by address
+func (srv *ApiServer) listenerFiles() ([]string, []*os.File, error) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	addrs := make([]string, 0, len(srv.listeners))
+	for addr := range srv.listeners {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	files := make([]*os.File, 0, len(addrs))
+	for _, addr := range addrs {
+		ln := srv.listeners[addr]
+		filer, ok := ln.(interface{ File() (*os.File, error) })
+		if !ok {
+			return nil, files, fmt.Errorf("listener %s can not be inherited", addr)
+		}
+		f, err := filer.File()
+		if err != nil {
+			return nil, files, fmt.Errorf("dup listener %s: %w", addr, err)
+		}
+		files = append(files, f)
+	}
+	return addrs, files, nil
+}
+
+func waitReady(pipe *os.File, timeout time.Duration) error {
+	_ = pipe.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 1)
+	if _, err := pipe.Read(buf); err != nil {
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return fmt.Errorf("no ready signal within %s", timeout)
+		}
+		return errors.New("child exited before ready")
+	}
+	return nil
+}
+
+func isRestartSignal(sig os.Signal) bool {
+	for _, restart := range restartSignals {
+		if sig == restart {
+			return true
+		}
+	}
+	return false
+}
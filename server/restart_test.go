@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	platform "github.com/chenxuan520/goweb-platform"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenerFiles(t *testing.T) {
+	srv := &ApiServer{}
+	ln, err := srv.listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer ln.Close()
+
+	addrs, files, err := srv.listenerFiles()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"127.0.0.1:0"}, addrs)
+	// the dup serves the same socket, as the child of a restart would
+	inherited, err := net.FileListener(files[0])
+	assert.Nil(t, err)
+	files[0].Close()
+	defer inherited.Close()
+
+	go func() {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err := inherited.Accept()
+	assert.Nil(t, err)
+	conn.Close()
+}
+
+func TestWaitReady(t *testing.T) {
+	r, w, err := os.Pipe()
+	assert.Nil(t, err)
+	go func(w *os.File) {
+		_, _ = w.Write([]byte{1})
+		w.Close()
+	}(w)
+	assert.Nil(t, waitReady(r, time.Second))
+	r.Close()
+
+	r, w, _ = os.Pipe()
+	w.Close()
+	assert.EqualError(t, waitReady(r, time.Second), "child exited before ready")
+	r.Close()
+
+	r, w, _ = os.Pipe()
+	defer w.Close()
+	assert.NotNil(t, waitReady(r, 10*time.Millisecond))
+	r.Close()
+}
+
+func TestShutdownHandoff(t *testing.T) {
+	initTestLogger(t)
+	newServer := func(status *string) *ApiServer {
+		srv := &ApiServer{Config: &platform.Config{System: platform.System{ShutdownWait: time.Minute}}}
+		srv.RegisterShutdown(func(srv *ApiServer) {
+			*status = srv.HealthReport().Status
+		})
+		return srv
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// the child of a restart serves the shared listeners, readiness stays up and there is
+	// no shutdownWait to sit through
+	var status string
+	srv := newServer(&status)
+	start := time.Now()
+	srv.shutdown(ctx, true)
+	assert.Equal(t, HealthStatusUp, status)
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+	assert.True(t, srv.isStopping())
+	// a stopping server does not restart again
+	assert.NotNil(t, srv.Restart())
+
+	srv = newServer(&status)
+	srv.Shutdown(ctx)
+	assert.Equal(t, HealthStatusDown, status)
+	assert.Equal(t, HealthStatusDown, srv.HealthReport().Status)
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+
+package server
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// restartSignals trigger Restart
+var restartSignals = []os.Signal{syscall.SIGUSR2}
+
+// startChild run the current binary with the same arguments, files become fd 3, 4...
+func startChild(env []string, files []*os.File) (*os.Process, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = env
+	cmd.ExtraFiles = files
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return cmd.Process, nil
+}
@@ -0,0 +1,16 @@
+//go:build windows
+
+package server
+
+import (
+	"errors"
+	"os"
+)
+
+// restartSignals is empty, windows has no SIGUSR2
+var restartSignals []os.Signal
+
+// startChild fail, windows processes can not inherit sockets as file descriptors
+func startChild(env []string, files []*os.File) (*os.Process, error) {
+	return nil, errors.New("hot restart is not supported on windows")
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	platform "github.com/chenxuan520/goweb-platform"
+)
+
+// secretCommand is the `secret` subcommand managing the ENC(...) values of the config files,
+// e.g. `app secret encrypt my-password` with CONFIG_SECRET_KEY set
+type secretCommand struct {
+	KeyFile string `long:"key-file" description:"file holding the base64 key, default CONFIG_SECRET_KEY or CONFIG_SECRET_KEY_FILE"`
+
+	Encrypt secretEncryptCommand `command:"encrypt" description:"encrypt values into ENC(...), read them from stdin when none is given"`
+	Decrypt secretDecryptCommand `command:"decrypt" description:"decrypt ENC(...) values, read them from stdin when none is given"`
+	Keygen  secretKeygenCommand  `command:"keygen" description:"generate a new base64 AES-256 key"`
+}
+
+type secretEncryptCommand struct{}
+
+func (cmd *secretEncryptCommand) Execute(args []string) error {
+	return convertSecrets(args, platform.EncryptSecret)
+}
+
+type secretDecryptCommand struct{}
+
+func (cmd *secretDecryptCommand) Execute(args []string) error {
+	return convertSecrets(args, platform.DecryptSecret)
+}
+
+type secretKeygenCommand struct{}
+
+func (cmd *secretKeygenCommand) Execute(args []string) error {
+	key, err := platform.GenerateSecretKey()
+	if err != nil {
+		return err
+	}
+	fmt.Println(key)
+	return nil
+}
+
+// convertSecrets print convert of every value, one per line, so plaintexts stay out of
+// the shell history when they are piped through stdin
+func convertSecrets(values []string, convert func(key []byte, value string) (string, error)) error {
+	key, err := platform.LoadSecretKey(ApiOptions.Secret.KeyFile)
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if line := strings.TrimRight(scanner.Text(), "\r"); line != "" {
+				values = append(values, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+	for _, value := range values {
+		out, err := convert(key, value)
+		if err != nil {
+			return err
+		}
+		fmt.Println(out)
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package servertest
+
+import (
+	"testing"
+
+	"github.com/chenxuan520/goweb-platform/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// ObserveLogs make the global logger record the entries at or above level in memory
+// until the test ends, request scoped loggers included. Call it before Start
+func ObserveLogs(t testing.TB, level zapcore.Level) *observer.ObservedLogs {
+	core, logs := observer.New(level)
+	old := logger.SetLogger(zap.New(core))
+	t.Cleanup(func() {
+		logger.SetLogger(old)
+	})
+	return logs
+}
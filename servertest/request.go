@@ -0,0 +1,219 @@
+package servertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Request is a request under construction, Do send it
+type Request struct {
+	s      *Server
+	method string
+	path   string
+	query  url.Values
+	header http.Header
+	body   []byte
+}
+
+// NewRequest start a request of method on path, relative to the server url
+func (s *Server) NewRequest(method, path string) *Request {
+	return &Request{s: s, method: method, path: path, query: url.Values{}, header: http.Header{}}
+}
+
+// GET start a GET request on path
+func (s *Server) GET(path string) *Request {
+	return s.NewRequest(http.MethodGet, path)
+}
+
+// POST start a POST request on path
+func (s *Server) POST(path string) *Request {
+	return s.NewRequest(http.MethodPost, path)
+}
+
+// PUT start a PUT request on path
+func (s *Server) PUT(path string) *Request {
+	return s.NewRequest(http.MethodPut, path)
+}
+
+// PATCH start a PATCH request on path
+func (s *Server) PATCH(path string) *Request {
+	return s.NewRequest(http.MethodPatch, path)
+}
+
+// DELETE start a DELETE request on path
+func (s *Server) DELETE(path string) *Request {
+	return s.NewRequest(http.MethodDelete, path)
+}
+
+// WithHeader set a request header
+func (r *Request) WithHeader(key, value string) *Request {
+	r.header.Set(key, value)
+	return r
+}
+
+// WithQuery add a query parameter
+func (r *Request) WithQuery(key, value string) *Request {
+	r.query.Add(key, value)
+	return r
+}
+
+// WithBody send body as is with the content type
+func (r *Request) WithBody(contentType string, body []byte) *Request {
+	r.header.Set("Content-Type", contentType)
+	r.body = body
+	return r
+}
+
+// WithJSON send v encoded as json
+func (r *Request) WithJSON(v interface{}) *Request {
+	r.s.t.Helper()
+	body, err := json.Marshal(v)
+	if err != nil {
+		r.s.t.Fatalf("servertest:encode json body failed , error:%s", err.Error())
+	}
+	return r.WithBody("application/json", body)
+}
+
+// WithForm send values url encoded
+func (r *Request) WithForm(values url.Values) *Request {
+	return r.WithBody("application/x-www-form-urlencoded", []byte(values.Encode()))
+}
+
+// Do send the request and read the whole response
+func (r *Request) Do() *Response {
+	t := r.s.t
+	t.Helper()
+	target := r.s.URL + r.path
+	if len(r.query) > 0 {
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target += sep + r.query.Encode()
+	}
+	var body io.Reader
+	if r.body != nil {
+		body = bytes.NewReader(r.body)
+	}
+	req, err := http.NewRequest(r.method, target, body)
+	if err != nil {
+		t.Fatalf("servertest:build request failed , error:%s", err.Error())
+	}
+	req.Header = r.header
+	resp, err := r.s.http.Client().Do(req)
+	if err != nil {
+		t.Fatalf("servertest:%s %s failed , error:%s", r.method, r.path, err.Error())
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("servertest:read response of %s %s failed , error:%s", r.method, r.path, err.Error())
+	}
+	return &Response{Response: resp, BodyBytes: data, t: t}
+}
+
+// Response is a received response, its assertions report to the test and chain
+type Response struct {
+	*http.Response
+	// BodyBytes is the whole body, the embedded Body is already read and closed
+	BodyBytes []byte
+
+	t testing.TB
+}
+
+// Status assert the status code
+func (r *Response) Status(code int) *Response {
+	r.t.Helper()
+	assert.Equal(r.t, code, r.StatusCode, "status of %s %s, body:%s", r.Request.Method, r.Request.URL.Path, r.BodyBytes)
+	return r
+}
+
+// AssertHeader assert the value of a response header
+func (r *Response) AssertHeader(key, value string) *Response {
+	r.t.Helper()
+	assert.Equal(r.t, value, r.Header.Get(key), "header %s", key)
+	return r
+}
+
+// Decode unmarshal the json body into v
+func (r *Response) Decode(v interface{}) *Response {
+	r.t.Helper()
+	if err := json.Unmarshal(r.BodyBytes, v); err != nil {
+		r.t.Fatalf("servertest:decode json body failed , error:%s, body:%s", err.Error(), r.BodyBytes)
+	}
+	return r
+}
+
+// JSONEq assert the body is the same json document as expected, whatever the key order
+func (r *Response) JSONEq(expected string) *Response {
+	r.t.Helper()
+	assert.JSONEq(r.t, expected, string(r.BodyBytes))
+	return r
+}
+
+// JSON assert the value at path of the json body equals expected once both are json
+// encoded, so 1 matches 1.0 and a struct matches its object. The path is dotted with
+// the array indexes as numbers, such as data.items.0.name, an empty path is the whole body
+func (r *Response) JSON(path string, expected interface{}) *Response {
+	r.t.Helper()
+	actual, ok := r.lookup(path)
+	if !ok {
+		r.t.Errorf("servertest:json path %q not found in body:%s", path, r.BodyBytes)
+		return r
+	}
+	want, err := json.Marshal(expected)
+	if err != nil {
+		r.t.Fatalf("servertest:encode expected value failed , error:%s", err.Error())
+	}
+	got, _ := json.Marshal(actual)
+	assert.JSONEq(r.t, string(want), string(got), "json path %q", path)
+	return r
+}
+
+// JSONExists assert path is present in the json body
+func (r *Response) JSONExists(path string) *Response {
+	r.t.Helper()
+	if _, ok := r.lookup(path); !ok {
+		r.t.Errorf("servertest:json path %q not found in body:%s", path, r.BodyBytes)
+	}
+	return r
+}
+
+// lookup walk the decoded body along the dotted path
+func (r *Response) lookup(path string) (interface{}, bool) {
+	r.t.Helper()
+	var node interface{}
+	if err := json.Unmarshal(r.BodyBytes, &node); err != nil {
+		r.t.Fatalf("servertest:decode json body failed , error:%s, body:%s", err.Error(), r.BodyBytes)
+	}
+	if path == "" {
+		return node, true
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch v := node.(type) {
+		case map[string]interface{}:
+			child, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			node = child
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			node = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return node, true
+}
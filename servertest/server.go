@@ -0,0 +1,69 @@
+// Package servertest run an ApiServer in process for the integration tests of a service.
+// The server goes through the same engine pipeline as ListenAndServe, platform middlewares
+// included, and is served by an httptest.Server
+package servertest
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	platform "github.com/chenxuan520/goweb-platform"
+	"github.com/chenxuan520/goweb-platform/logger"
+	"github.com/chenxuan520/goweb-platform/server"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Server is a started ApiServer with its test http server
+type Server struct {
+	*server.ApiServer
+	// URL is the base url of the test http server, such as http://127.0.0.1:41231
+	URL string
+
+	t    testing.TB
+	http *httptest.Server
+}
+
+// New build an ApiServer of cfg and opts with server.New, a nil cfg is an empty config.
+// Register the routers on it, then Start it
+func New(t testing.TB, cfg *platform.Config, opts ...server.Applier) *server.ApiServer {
+	t.Helper()
+	if cfg == nil {
+		cfg = &platform.Config{}
+	}
+	srv, err := server.New(cfg, opts...)
+	if err != nil {
+		t.Fatalf("servertest:new api server failed , error:%s", err.Error())
+	}
+	return srv
+}
+
+// Start build the engine of srv, run its start hooks and serve it. The stop hooks run and
+// the http server closes when the test ends, the config reload subscriptions of srv with
+// them. Without a logger set, logs are discarded
+func Start(t testing.TB, srv *server.ApiServer) *Server {
+	t.Helper()
+	if logger.GetLogger() == nil {
+		logger.SetLogger(zap.NewNop())
+		t.Cleanup(func() {
+			logger.SetLogger(nil)
+		})
+	}
+	gin.SetMode(gin.TestMode)
+
+	engine := srv.BuildEngine()
+	if _, err := srv.Lifecycle.Start(context.Background()); err != nil {
+		t.Fatalf("servertest:start hooks failed , error:%s", err.Error())
+	}
+	ts := httptest.NewUnstartedServer(engine)
+	ts.Config = srv.NewHttpServer(engine, "")
+	ts.Start()
+	t.Cleanup(func() {
+		ts.Close()
+		if _, err := srv.Lifecycle.Stop(context.Background()); err != nil {
+			t.Errorf("servertest:stop hooks failed , error:%s", err.Error())
+		}
+	})
+	return &Server{ApiServer: srv, URL: ts.URL, t: t, http: ts}
+}
@@ -0,0 +1,95 @@
+package servertest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	platform "github.com/chenxuan520/goweb-platform"
+	"github.com/chenxuan520/goweb-platform/logger"
+	"github.com/chenxuan520/goweb-platform/mysql"
+	"github.com/chenxuan520/goweb-platform/redis"
+	"github.com/chenxuan520/goweb-platform/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type user struct {
+	ID   uint   `json:"id" gorm:"primaryKey"`
+	Name string `json:"name"`
+}
+
+func TestServer(t *testing.T) {
+	var stopped bool
+	t.Run("serve", func(t *testing.T) {
+		serve(t, &stopped)
+	})
+	// the stop hooks run when the test ends
+	assert.True(t, stopped)
+}
+
+func serve(t *testing.T, stopped *bool) {
+	logs := ObserveLogs(t, zap.InfoLevel)
+	mr := Redis(t)
+	SQLite(t, &user{})
+
+	srv := New(t, &platform.Config{System: platform.System{MaxBodySize: 64}})
+	srv.OnStop("check", func(ctx context.Context) error {
+		*stopped = true
+		return nil
+	})
+	srv.RegisterRouters(func(engine *gin.Engine) {
+		engine.POST("/users", func(c *gin.Context) {
+			var u user
+			if err := c.ShouldBindJSON(&u); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+				return
+			}
+			mysql.GetMysqlDB().Create(&u)
+			redis.GetRedis().Set(c, "last", u.Name, time.Minute)
+			logger.FromContext(c).Info("user created")
+			c.JSON(http.StatusOK, gin.H{"data": u})
+		})
+		engine.GET("/panic", func(c *gin.Context) {
+			panic("boom")
+		})
+		engine.GET("/users", func(c *gin.Context) {
+			var users []user
+			mysql.GetMysqlDB().Order("id").Find(&users)
+			c.JSON(http.StatusOK, gin.H{"data": users, "last": redis.GetRedis().Get(c, "last").Val()})
+		})
+	})
+	s := Start(t, srv)
+
+	s.POST("/users").WithHeader(utils.HeaderRequestID, "req-1").WithJSON(user{Name: "alice"}).Do().
+		Status(http.StatusOK).
+		AssertHeader(utils.HeaderRequestID, "req-1").
+		JSON("data.id", 1).
+		JSON("data.name", "alice")
+	s.GET("/users").WithQuery("page", "1").Do().
+		Status(http.StatusOK).
+		JSON("data.0", user{ID: 1, Name: "alice"}).
+		JSON("last", "alice").
+		JSONExists("data")
+	// the fields of the http.Response stay reachable besides the read body
+	resp := s.GET("/users").Do()
+	assert.Equal(t, "application/json; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Contains(t, string(resp.BodyBytes), "alice")
+	// the platform middlewares run, MaxBodySize included
+	s.POST("/users").WithJSON(user{Name: string(make([]byte, 100))}).Do().
+		Status(http.StatusRequestEntityTooLarge)
+
+	// a panic is answered in the response envelope
+	s.GET("/panic").WithHeader(utils.HeaderRequestID, "req-2").Do().
+		Status(http.StatusInternalServerError).
+		JSONEq(`{"code": 50000, "message": "internal server error", "request_id": "req-2"}`)
+
+	last, _ := mr.Get("last")
+	assert.Equal(t, "alice", last)
+	entries := logs.FilterMessage("user created").All()
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "req-1", entries[0].ContextMap()["request_id"])
+	}
+}
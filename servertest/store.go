@@ -0,0 +1,54 @@
+package servertest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/chenxuan520/goweb-platform/mysql"
+	"github.com/chenxuan520/goweb-platform/redis"
+	goredis "github.com/go-redis/redis/v8"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Redis start an in-memory redis and make it the default client of the redis package
+// until the test ends. Use the returned server to seed data or fast forward the ttl
+func Redis(t testing.TB) *miniredis.Miniredis {
+	t.Helper()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("servertest:start miniredis failed , error:%s", err.Error())
+	}
+	client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	old := redis.SetRedis(client)
+	t.Cleanup(func() {
+		redis.SetRedis(old)
+		_ = client.Close()
+		mr.Close()
+	})
+	return mr
+}
+
+// SQLite open a sqlite database in the test temp dir and make it the default DB of the
+// mysql package until the test ends. models are auto migrated
+func SQLite(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("servertest:open sqlite failed , error:%s", err.Error())
+	}
+	if len(models) > 0 {
+		if err := db.AutoMigrate(models...); err != nil {
+			t.Fatalf("servertest:migrate sqlite failed , error:%s", err.Error())
+		}
+	}
+	old := mysql.SetMysqlDB(db)
+	t.Cleanup(func() {
+		mysql.SetMysqlDB(old)
+		if sqlDB, err := db.DB(); err == nil {
+			_ = sqlDB.Close()
+		}
+	})
+	return db
+}